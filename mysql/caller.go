@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// packagePrefix is the prefix of every function in this package, generics included
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	pkgEnd := strings.LastIndex(name, "/") + 1
+	return name[:pkgEnd+strings.Index(name[pkgEnd:], ".")+1]
+}()
+
+// subpackagePrefix is the prefix of the packages nested in this one (mysqltest, migrations, ...)
+var subpackagePrefix = strings.TrimSuffix(packagePrefix, ".") + "/"
+
+// externalCaller gets the file:line of the first caller outside this package, its subpackages and the sql packages it
+// calls into
+func externalCaller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) &&
+			!isSubpackage(frame.Function) &&
+			!strings.HasPrefix(frame.Function, "database/sql.") &&
+			!strings.HasPrefix(frame.Function, "github.com/jmoiron/sqlx.") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// isSubpackage checks if function belongs to a package nested in this one, their tests are callers like any other
+func isSubpackage(function string) bool {
+	if !strings.HasPrefix(function, subpackagePrefix) {
+		return false
+	}
+	pkg := function[len(subpackagePrefix):]
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	if end := strings.Index(pkg, "."); end >= 0 {
+		pkg = pkg[:end]
+	}
+	return !strings.HasSuffix(pkg, "_test")
+}
@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/weisbartb/scene"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tag is a key/value pair statements are annotated with, see WithSQLCommenter and Instance.WithTags
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// SQLCommenterOptions decides which tags from the scene context are added to every statement
+type SQLCommenterOptions struct {
+	// Application is added as the application tag, usually the name of the service
+	Application string
+	// RequestID adds the scene's request id as the request_id tag
+	RequestID bool
+	// Traceparent adds the W3C traceparent of the span in the scene context
+	Traceparent bool
+	// Tags pulls any other tags from the scene context, such as the route
+	Tags func(ctx context.Context) []Tag
+}
+
+// WithSQLCommenter prefixes every statement with a sqlcommenter comment (/*key='value',...*/) so statements in
+// performance_schema and the slow log can be mapped back to the request that issued them.
+func WithSQLCommenter(opts SQLCommenterOptions) ProviderOption {
+	return func(provider *Provider) {
+		provider.commenter = &opts
+	}
+}
+
+// WithTags gets a view of this instance that adds tags to every statement it (and the children spawned from it)
+// runs, a tag replaces any tag with the same key from the provider. This instance is left untagged.
+// The view shares this instance's transaction and open rows, so it can be taken at any point of a transaction.
+func (d *Instance) WithTags(tags ...Tag) *Instance {
+	i := d.derive(d.ctx)
+	i.session = d.session
+	i.stats = d.stats
+	i.writes = d.writes
+	i.tags = append(d.tags[:len(d.tags):len(d.tags)], tags...)
+	return i
+}
+
+// comment prefixes query with the tags of this instance, it is returned as is if there are none
+func (d *Instance) comment(ctx context.Context, query string) string {
+	if len(query) == 0 || (d.commenter == nil && len(d.tags) == 0) {
+		return query
+	}
+	var tags []Tag
+	if opts := d.commenter; opts != nil {
+		if len(opts.Application) > 0 {
+			tags = append(tags, Tag{Key: "application", Value: opts.Application})
+		}
+		if requestID := scene.GetRequestID(ctx); opts.RequestID && len(requestID) > 0 {
+			tags = append(tags, Tag{Key: "request_id", Value: requestID})
+		}
+		if spanCtx := trace.SpanContextFromContext(ctx); opts.Traceparent && spanCtx.IsValid() {
+			tags = append(tags, Tag{Key: "traceparent", Value: "00-" + spanCtx.TraceID().String() + "-" +
+				spanCtx.SpanID().String() + "-" + spanCtx.TraceFlags().String()})
+		}
+		if opts.Tags != nil {
+			tags = append(tags, opts.Tags(ctx)...)
+		}
+	}
+	tags = append(tags, d.tags...)
+	if comment := FormatSQLComment(tags); len(comment) > 0 {
+		return comment + " " + query
+	}
+	return query
+}
+
+// FormatSQLComment serializes tags into a sqlcommenter comment. Keys and values are URL encoded, so they can never
+// close the comment, and the tags are sorted by key. A later tag replaces an earlier one with the same key.
+func FormatSQLComment(tags []Tag) string {
+	byKey := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if len(tag.Key) > 0 {
+			byKey[tag.Key] = tag.Value
+		}
+	}
+	if len(byKey) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, commentEscape(key)+"='"+commentEscape(byKey[key])+"'")
+	}
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// commentEscape URL encodes a key or value, spaces are encoded as %20 per the sqlcommenter spec
+func commentEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
@@ -0,0 +1,114 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFormatSQLComment(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []mysql.Tag
+		want string
+	}{
+		{
+			name: "Empty",
+			want: "",
+		},
+		{
+			name: "Sorted",
+			tags: []mysql.Tag{{Key: "route", Value: "/users/{id}"}, {Key: "application", Value: "orders api"}},
+			want: "/*application='orders%20api',route='%2Fusers%2F%7Bid%7D'*/",
+		},
+		{
+			name: "Escaped",
+			tags: []mysql.Tag{{Key: "it's", Value: "*/ DROP TABLE users; /*'"}},
+			want: "/*it%27s='%2A%2F%20DROP%20TABLE%20users%3B%20%2F%2A%27'*/",
+		},
+		{
+			name: "Replaced",
+			tags: []mysql.Tag{{Key: "route", Value: "a"}, {Key: "", Value: "skipped"}, {Key: "route", Value: "b"}},
+			want: "/*route='b'*/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, mysql.FormatSQLComment(tt.tags))
+		})
+	}
+}
+
+func TestInstance_WithTags(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	ctx := internal.NewScene(t, nil,
+		mysql.WithDB(internal.OpenFakeDB(t, "tagged")),
+		mysql.WithTracing(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		mysql.WithSQLCommenter(mysql.SQLCommenterOptions{
+			Application: "orders",
+			RequestID:   true,
+			Traceparent: true,
+			Tags: func(ctx context.Context) []mysql.Tag {
+				return []mysql.Tag{{Key: "route", Value: "/orders"}}
+			},
+		}),
+	)
+	scoped := mysql.GetManagedDatabaseInstance(ctx)
+	instance := scoped.WithTags(mysql.Tag{Key: "route", Value: "/orders/{id}"})
+	_, err := instance.Exec("DELETE FROM orders")
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans().Snapshots()
+	require.Len(t, spans, 1)
+	spanCtx := spans[0].SpanContext()
+	require.Equal(t, "/*application='orders',request_id='"+scene.GetRequestID(ctx)+"',route='%2Forders%2F%7Bid%7D',"+
+		"traceparent='00-"+spanCtx.TraceID().String()+"-"+spanCtx.SpanID().String()+"-01'*/ DELETE FROM orders",
+		internal.FakeDBStatements("tagged")[0])
+	require.Equal(t, "DELETE FROM orders", spanAttrs(spans[0])["db.statement"].AsString())
+	require.Equal(t, map[string]int{"DELETE FROM orders": 1}, instance.Stats().Statements)
+
+	exporter.Reset()
+	_, err = scoped.Exec("DELETE FROM orders")
+	require.NoError(t, err)
+	require.Contains(t, internal.FakeDBStatements("tagged")[1], "route='%2Forders'", "the scene instance keeps the provider's tags")
+	require.Equal(t, map[string]int{"DELETE FROM orders": 2}, scoped.Stats().Statements)
+
+	untagged := mysql.NewInstance(context.Background(), instance.Raw())
+	_, err = untagged.WithTags(mysql.Tag{Key: "job", Value: "cleanup"}).Exec("DELETE FROM orders")
+	require.NoError(t, err)
+	require.Equal(t, "/*job='cleanup'*/ DELETE FROM orders", internal.FakeDBStatements("tagged")[2])
+}
+
+func TestInstance_WithTagsInTransaction(t *testing.T) {
+	instance := internal.NewInstance(t, nil, mysql.WithDB(internal.OpenFakeDB(t, "tagged_tx")))
+	rollback := errors.New("rollback")
+	err := instance.RequireTx(func(db *mysql.Instance) error {
+		tagged := db.WithTags(mysql.Tag{Key: "job", Value: "cleanup"})
+		require.True(t, tagged.InTx())
+		if _, err := tagged.Exec("DELETE FROM orders"); err != nil {
+			return err
+		}
+		return tagged.RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("DELETE FROM users")
+			require.NoError(t, err)
+			return rollback
+		})
+	})
+	require.ErrorIs(t, err, rollback)
+	require.False(t, instance.InTx())
+	require.Equal(t, []string{
+		"BEGIN",
+		"/*job='cleanup'*/ DELETE FROM orders",
+		"/*job='cleanup'*/ SAVEPOINT scene_sp_1",
+		"/*job='cleanup'*/ DELETE FROM users",
+		"/*job='cleanup'*/ ROLLBACK TO SAVEPOINT scene_sp_1",
+		"ROLLBACK",
+	}, internal.FakeDBStatements("tagged_tx"))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/weisbartb/deadline-wg"
 	"github.com/weisbartb/scene"
 	"github.com/weisbartb/stack"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type logger interface {
@@ -17,73 +18,290 @@ type logger interface {
 	Errorf(format string, v ...interface{})
 }
 
+// warnLogger is implemented by loggers that support a warning level
+type warnLogger interface {
+	Warnf(format string, v ...interface{})
+}
+
+// warnf gets the function warnings are logged with, falling back to Errorf if log has no warning level
+func warnf(log logger) func(format string, v ...interface{}) {
+	if warn, ok := log.(warnLogger); ok {
+		return warn.Warnf
+	}
+	return log.Errorf
+}
+
 var ShutdownErr = errors.New("mysql did not shutdown cleanly")
 
+// DefaultShutdownTimeout is how long in-flight work is given to drain, and then how long the pools are given to close,
+// when the factory unmounts
+const DefaultShutdownTimeout = time.Second * 3
+
 type CtxContextKey struct{}
 
+// CtxNamedContextKey is the context key instances of a named provider are stored under, see WithName
+type CtxNamedContextKey struct {
+	Name string
+}
+
 type Provider struct {
 	scene.BaseProvider
-	logger          logger
-	DB              *sqlx.DB
-	closeOnShutdown bool
+	logger logger
+	DB     *sqlx.DB
+	// Replicas receive reads made outside a transaction, this is optional
+	Replicas *ReplicaPool
+	// CommitOnSuccess gives every scene unit of work semantics, a transaction is opened on the first write and is
+	// committed when the scene completes without an error, otherwise it is rolled back.
+	// RequireTxRetry runs f only once in these scenes, the work before it can not be replayed.
+	CommitOnSuccess    bool
+	name               string
+	closeOnShutdown    bool
+	closeOnShutdownSet bool
+	shutdownTimeout    time.Duration
+	contextKey         any
+	onNewInstance      []InstanceHook
+	onCompleteInstance []InstanceCompleteHook
+	tracker            *instanceTracker
+	tracer             trace.Tracer
+	metrics            MetricsCollector
+	interceptors       []Interceptor
+	slowQueries        *SlowQueryOptions
+	nPlusOneThreshold  int
+	commenter          *SQLCommenterOptions
+	queryErrors        *QueryErrorOptions
+	testTx             *testTx
 }
 
-func NewSceneProvider(cfg MySQLConfig, loggingInstance logger) (Provider, error) {
-	db, err := sqlx.Connect("mysql", cfg.BuildDSN())
-	if err != nil {
+// NewSceneProvider creates a provider from the configuration, by default it connects and owns its own pool.
+func NewSceneProvider(cfg MySQLConfig, loggingInstance logger, opts ...ProviderOption) (Provider, error) {
+	provider := Provider{
+		logger:  loggingInstance,
+		tracker: newInstanceTracker(),
+	}
+	for _, opt := range opts {
+		opt(&provider)
+	}
+	if !provider.closeOnShutdownSet {
+		// A pool that was handed in belongs to the caller
+		provider.closeOnShutdown = provider.DB == nil
+	}
+	// Replica settings are checked before connecting so that nothing is left open when they are invalid
+	buildReplicas := provider.Replicas == nil && len(cfg.ReplicaHosts) > 0
+	consistency, err := ParseReadConsistency(cfg.ReplicaConsistency)
+	if buildReplicas && err != nil {
 		return Provider{}, stack.Trace(err)
 	}
-	db.SetMaxOpenConns(50)
-
-	db.SetConnMaxLifetime(time.Second * 60 * 15) // 15 minutes which should align to the AWS default
-	db.SetMaxIdleConns(25)
-	return Provider{
-		DB:              db,
-		closeOnShutdown: true,
-		logger:          loggingInstance,
-	}, stack.Trace(err)
+	gtidWaitTimeout := DefaultGTIDWaitTimeout
+	if buildReplicas && len(cfg.ReplicaGTIDWaitTimeout) > 0 {
+		if gtidWaitTimeout, err = time.ParseDuration(cfg.ReplicaGTIDWaitTimeout); err != nil || gtidWaitTimeout <= 0 {
+			return Provider{}, stack.Trace(ErrInvalidGTIDWaitTimeout)
+		}
+	}
+	ownsDB := provider.DB == nil
+	// Pool settings are checked before connecting as well, only pools opened here are sized by them
+	var pool poolSettings
+	if ownsDB || buildReplicas {
+		if pool, err = cfg.poolSettings(); err != nil {
+			return Provider{}, stack.Trace(err)
+		}
+	}
+	if ownsDB {
+		if provider.DB, err = sqlx.Connect("mysql", cfg.BuildDSN()); err != nil {
+			return Provider{}, stack.Trace(err)
+		}
+		pool.apply(provider.DB)
+	}
+	if buildReplicas {
+		var replicas []*sqlx.DB
+		fail := func(err error) (Provider, error) {
+			for _, replica := range replicas {
+				_ = replica.Close()
+			}
+			if ownsDB {
+				_ = provider.DB.Close()
+			}
+			return Provider{}, stack.Trace(err)
+		}
+		for _, dsn := range cfg.BuildReplicaDSNs() {
+			// Replicas are opened lazily so that one being down does not fail startup, it is ejected until healthy.
+			// Startup still waits on the initial health check of NewReplicaPool, up to its ping limit.
+			replica, err := sqlx.Open("mysql", dsn)
+			if err != nil {
+				return fail(err)
+			}
+			pool.apply(replica)
+			replicas = append(replicas, replica)
+		}
+		provider.Replicas = NewReplicaPool(DefaultReplicaHealthCheckInterval, replicas...)
+		provider.Replicas.Consistency = consistency
+		provider.Replicas.GTIDWaitTimeout = gtidWaitTimeout
+	}
+	// Tracing, metrics and the slow query log wrap everything else so they observe the outcome of the other interceptors
+	var chain []Interceptor
+	if provider.tracer != nil {
+		chain = append(chain, tracingInterceptor(provider.tracer))
+	}
+	if provider.metrics != nil {
+		chain = append(chain, metricsInterceptor(provider.metrics, provider.name))
+		provider.metrics.RegisterPools(provider.name, provider.poolStats)
+	}
+	if provider.slowQueries != nil && provider.logger != nil {
+		chain = append(chain, newSlowQueryLog(*provider.slowQueries, provider.logger, provider.name, provider.DB).interceptor())
+	}
+	provider.interceptors = append(chain, provider.interceptors...)
+	return provider, nil
 }
 
 // Provider uses a global database pool rather than a factory managed pool. This is intentional
 
+// OnFactoryUnmount stops handing out new instances and waits for in-flight transactions and rows to finish
+// (up to the shutdown timeout). Anything still open then is rolled back and closed before the pools are closed with
+// whatever is left of the timeout. If work was still in flight, or the pools could not close, a *ShutdownError is
+// returned describing what was left open.
 func (i Provider) OnFactoryUnmount(valuer scene.FactoryDefaultValuer) error {
-	if i.closeOnShutdown {
-		wg := deadlinewg.NewWaitGroup(time.Second * 3)
-		wg.Add(1)
-		var closeErr error
-		go func() {
-			defer wg.Done()
-			closeErr = i.DB.Close()
-		}()
-		wgErr := wg.Wait()
-		if wgErr != nil {
-			return closeErr
+	timeout := i.shutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	var inFlight []InFlightWork
+	if i.tracker != nil {
+		if inFlight = i.tracker.drain(deadline); len(inFlight) > 0 {
+			i.tracker.forceClose()
 		}
-		return ShutdownErr
+	}
+	var closeErr error
+	if i.closeOnShutdown {
+		closeErr = i.closePools(deadline)
+	}
+	if len(inFlight) > 0 || closeErr != nil {
+		return &ShutdownError{Provider: i.name, InFlight: inFlight, CloseErr: closeErr}
 	}
 	return nil
 }
 
+// closePools closes the primary and replica pools, giving up at the deadline. If the deadline already passed the
+// pools are closed in the background.
+func (i Provider) closePools(deadline time.Time) error {
+	wg := deadlinewg.NewWaitGroup(time.Until(deadline))
+	wg.Add(1)
+	closeErr := make(chan error, 1)
+	go func() {
+		defer wg.Done()
+		err := i.DB.Close()
+		if i.Replicas != nil {
+			if replicaErr := i.Replicas.Close(); replicaErr != nil && err == nil {
+				err = replicaErr
+			}
+		}
+		closeErr <- err
+	}()
+	if !time.Now().Before(deadline) {
+		return errors.Wrap(deadlinewg.ErrTimeout, "the shutdown timeout passed while draining, the pools are closing in the background")
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+	return <-closeErr
+}
+
 func (i Provider) OnNewContext(ctx scene.Context) {
 	// There should be a default logger that is
-	val := ctx.Value(CtxContextKey{})
+	key := i.Key()
+	val := ctx.Value(key)
 	if val == nil {
 		instance := NewInstance(ctx, i.DB)
+		if i.tracker != nil && !i.tracker.add(instance) {
+			if i.logger != nil {
+				i.logger.Errorf("Providing a closed database instance%v, the provider is shutting down", i.logName())
+			}
+			// Callers get ErrShuttingDown from the instance rather than a nil instance
+			instance.unavailable = ErrShuttingDown
+			ctx.Store(key, instance)
+			return
+		}
+		instance.unitOfWork = i.CommitOnSuccess
+		instance.replicas = i.Replicas
+		instance.interceptors = i.interceptors
+		instance.metrics = i.metrics
+		instance.provider = i.name
+		instance.commenter = i.commenter
+		instance.queryErrors = i.queryErrors
+		instance.testTx = i.testTx
+		for _, hook := range i.onNewInstance {
+			hook(ctx, instance)
+		}
 		ctx.Defer(func(ctx scene.Context, completeErr error) {
+			for _, hook := range i.onCompleteInstance {
+				hook(ctx, instance, completeErr)
+			}
+			if i.nPlusOneThreshold > 0 && i.logger != nil {
+				for statement, ct := range instance.Stats().Repeated(i.nPlusOneThreshold) {
+					warnf(i.logger)("Statement ran %v times in one scene%v, this is likely an N+1: %v", ct, i.logName(), statement)
+				}
+			}
+			if instance.unitOfWork {
+				if err := instance.completeUnitOfWork(completeErr); err != nil {
+					if i.logger != nil {
+						i.logger.Errorf("Could not complete the unit of work on context completion%v. %v", i.logName(), err)
+					}
+				}
+			}
 			if err := instance.Close(); err != nil {
 				if i.logger != nil {
-					i.logger.Errorf("Could not close connection on context completion. If you are seeing this, there is a bug in your code. %v", err)
+					i.logger.Errorf("Could not close connection on context completion%v. If you are seeing this, there is a bug in your code. %v", i.logName(), err)
 				}
 			}
-			ctx.Store(CtxContextKey{}, nil)
+			if i.tracker != nil {
+				i.tracker.remove(instance)
+			}
+			ctx.Store(key, nil)
 		})
 		// Add the database
-		ctx.Store(CtxContextKey{}, instance)
+		ctx.Store(key, instance)
 	}
 }
 
+// Name gets the name the provider was registered with, the default provider has no name
+func (i Provider) Name() string {
+	return i.name
+}
+
+// logName gets the name formatted for log messages
+func (i Provider) logName() string {
+	return logName(i.name)
+}
+
+func logName(name string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	return " [" + name + "]"
+}
+
+// Key gets the context key instances are stored under, see GetManagedDatabaseInstanceByKey
+func (i Provider) Key() any {
+	if i.contextKey == nil {
+		return CtxContextKey{}
+	}
+	return i.contextKey
+}
+
 func GetManagedDatabaseInstance(ctx context.Context) *Instance {
-	if val := ctx.Value(CtxContextKey{}); val != nil {
+	return GetManagedDatabaseInstanceByKey(ctx, CtxContextKey{})
+}
+
+// GetManagedDatabaseInstanceNamed gets the instance of a provider that was set up using WithName
+func GetManagedDatabaseInstanceNamed(ctx context.Context, name string) *Instance {
+	if len(name) == 0 {
+		return GetManagedDatabaseInstance(ctx)
+	}
+	return GetManagedDatabaseInstanceByKey(ctx, CtxNamedContextKey{Name: name})
+}
+
+// GetManagedDatabaseInstanceByKey gets the instance of a provider that was set up using WithContextKey
+func GetManagedDatabaseInstanceByKey(ctx context.Context, key any) *Instance {
+	if val := ctx.Value(key); val != nil {
 		return val.(*Instance)
 	}
 	return nil
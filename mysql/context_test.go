@@ -1,6 +1,8 @@
 package mysql_test
 
 import (
+	"context"
+	"errors"
 	"github.com/weisbartb/scene"
 	"github.com/weisbartb/scene-db/mysql"
 	"github.com/weisbartb/scene-db/mysql/internal"
@@ -45,9 +47,7 @@ func TestProviderAllocation(t *testing.T) {
 	db, shutdown := internal.InitializeTestDB(t, false)
 	defer shutdown()
 	require.NotNil(t, db)
-	provider := mysql.Provider{
-		DB: db,
-	}
+	provider := must(mysql.NewSceneProvider(internal.GetTestDatabaseConfiguration(), nil, mysql.WithDB(db)))
 	db.SetMaxOpenConns(50)
 	factory, err := scene.NewSceneFactory(scene.Config{MaxTTL: time.Second * 4}, provider)
 	require.NoError(t, err)
@@ -69,3 +69,45 @@ func TestProviderAllocation(t *testing.T) {
 	wg.Wait()
 	require.Equal(t, 0, db.Stats().InUse)
 }
+
+func TestProviderCommitOnSuccess(t *testing.T) {
+	db, shutdown := internal.InitializeTestDB(t, false)
+	defer shutdown()
+	factory, err := scene.NewSceneFactory(scene.Config{MaxTTL: time.Second * 4}, must(mysql.NewSceneProvider(
+		internal.GetTestDatabaseConfiguration(), nil, mysql.WithDB(db), mysql.WithCommitOnSuccess(true),
+	)))
+	require.NoError(t, err)
+	count := func() (ct int) {
+		require.NoError(t, mysql.NewInstance(context.Background(), db).QueryRow("SELECT count(*) FROM test_kvp").Scan(&ct))
+		return
+	}
+
+	ctx, _ := factory.NewCtx()
+	instance := mysql.GetManagedDatabaseInstance(ctx)
+	require.Equal(t, 0, count())
+	require.False(t, instance.InTx())
+	_, err = instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test')")
+	require.NoError(t, err)
+	require.True(t, instance.InTx())
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		_, err := db.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test2','test2')")
+		return err
+	}))
+	require.True(t, instance.InTx())
+	require.Equal(t, 0, count())
+	ctx.Complete()
+	require.Equal(t, 2, count())
+
+	ctx, _ = factory.NewCtx()
+	instance = mysql.GetManagedDatabaseInstance(ctx)
+	var rolledBack error
+	_, err = instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test3','test3')")
+	require.NoError(t, err)
+	instance.AfterRollback(func(err error) {
+		rolledBack = err
+	})
+	ctx.CompleteWithError(errors.New("handler failed"))
+	require.EqualError(t, rolledBack, "handler failed")
+	require.Equal(t, 2, count())
+	require.Equal(t, 0, db.Stats().InUse)
+}
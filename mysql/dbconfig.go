@@ -11,8 +11,10 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 )
 
 var tlsIDCounter int32
@@ -27,6 +29,19 @@ var ErrInvalidDatabasePort = errors.New("invalid database port provided")
 var ErrInvalidDatabaseSchemaName = errors.New("invalid database schema name provided")
 var ErrNoCABundleProvided = errors.New("TLS requires a CA bundle")
 var ErrInvalidCABundleProvided = errors.New("TLS requires a valid CA bundle")
+var ErrInvalidReplicaHost = errors.New("invalid replica host provided")
+var ErrInvalidGTIDWaitTimeout = errors.New("invalid GTID wait timeout provided")
+var ErrInvalidMaxOpenConns = errors.New("invalid max open connections provided")
+var ErrInvalidMaxIdleConns = errors.New("invalid max idle connections provided")
+var ErrInvalidConnMaxLifetime = errors.New("invalid connection max lifetime provided")
+var ErrInvalidConnMaxIdleTime = errors.New("invalid connection max idle time provided")
+
+// Pool defaults used when the configuration leaves them blank
+const (
+	DefaultMaxOpenConns    = 50
+	DefaultMaxIdleConns    = 25
+	DefaultConnMaxLifetime = time.Minute * 15 // Aligns to the AWS default
+)
 
 type NestedMySQLConfigWrapper struct {
 	Cfg MySQLConfig `toml:"database" json:"database" yaml:"database,flow"`
@@ -56,8 +71,25 @@ type MySQLConfig struct {
 	SQLMode string `toml:"sqlMode" json:"sqlMode,omitempty" yaml:"sqlMode,omitempty"`
 	// What is the transaction level - defaults to REPEATABLE-READ
 	TXNIsolation string ` toml:"txnIsolation" json:"txnIsolation,omitempty" yaml:"txnIsolation,omitempty"`
+	// Read replica hosts (host or host:port, the port defaults to the primary's port), reads are balanced across these
+	ReplicaHosts []string `toml:"replicas" json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	// How reads are routed after a scene writes to the primary: eventual (default), primary or gtid
+	ReplicaConsistency string `toml:"replicaConsistency" json:"replicaConsistency,omitempty" yaml:"replicaConsistency,omitempty"`
+	// How long a replica is waited on to apply a scene's writes under gtid consistency, e.g. 500ms (defaults to 1s)
+	ReplicaGTIDWaitTimeout string `toml:"replicaGTIDWaitTimeout" json:"replicaGTIDWaitTimeout,omitempty" yaml:"replicaGTIDWaitTimeout,omitempty"`
+	// The maximum number of open connections per pool (defaults to 50)
+	MaxOpenConns int `toml:"maxOpenConns" json:"maxOpenConns,omitempty" yaml:"maxOpenConns,omitempty"`
+	// The maximum number of idle connections per pool, can not exceed MaxOpenConns (defaults to 25, or MaxOpenConns
+	// when that is lower)
+	MaxIdleConns int `toml:"maxIdleConns" json:"maxIdleConns,omitempty" yaml:"maxIdleConns,omitempty"`
+	// How long a connection can be reused for, e.g. 15m (defaults to 15m)
+	ConnMaxLifetime string `toml:"connMaxLifetime" json:"connMaxLifetime,omitempty" yaml:"connMaxLifetime,omitempty"`
+	// How long a connection can sit idle before it is closed, e.g. 5m (defaults to no limit)
+	ConnMaxIdleTime string `toml:"connMaxIdleTime" json:"connMaxIdleTime,omitempty" yaml:"connMaxIdleTime,omitempty"`
 	// The TLS extension id that was registered
 	tlsID string
+	// The TLS extension ids registered for each replica host
+	replicaTLSIDs []string
 }
 
 func DefaultMySQLCfg() MySQLConfig {
@@ -73,6 +105,9 @@ func DefaultMySQLCfg() MySQLConfig {
 		TLSEnabled:         false,
 		SQLMode:            "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION,ANSI_QUOTES",
 		TXNIsolation:       "REPEATABLE-READ",
+		MaxOpenConns:       DefaultMaxOpenConns,
+		MaxIdleConns:       DefaultMaxIdleConns,
+		ConnMaxLifetime:    DefaultConnMaxLifetime.String(),
 	}
 }
 
@@ -102,6 +137,35 @@ func (dbCfg *MySQLConfig) Validate(getPem func() ([]byte, error)) error {
 	if len(dbCfg.DatabaseCharSet) == 0 {
 		dbCfg.DatabaseCharSet = "utf8mb4,utf8"
 	}
+	for _, replicaHost := range dbCfg.ReplicaHosts {
+		host, port := dbCfg.splitReplicaHost(replicaHost)
+		if len(host) == 0 {
+			return ErrInvalidReplicaHost
+		}
+		if _, err = net.LookupHost(host); err != nil {
+			return ErrUnreachableDatabaseHost
+		}
+		if matched, err := regexp.MatchString(`^\d+$`, port); err != nil || !matched {
+			return ErrInvalidDatabasePort
+		}
+	}
+	if _, err = ParseReadConsistency(dbCfg.ReplicaConsistency); err != nil {
+		return err
+	}
+	if len(dbCfg.ReplicaGTIDWaitTimeout) > 0 {
+		if timeout, err := time.ParseDuration(dbCfg.ReplicaGTIDWaitTimeout); err != nil || timeout <= 0 {
+			return ErrInvalidGTIDWaitTimeout
+		}
+	}
+	pool, err := dbCfg.poolSettings()
+	if err != nil {
+		return err
+	}
+	dbCfg.MaxOpenConns = pool.maxOpen
+	dbCfg.MaxIdleConns = pool.maxIdle
+	if len(dbCfg.ConnMaxLifetime) == 0 {
+		dbCfg.ConnMaxLifetime = pool.lifetime.String()
+	}
 	if dbCfg.TLSEnabled {
 		if err = dbCfg.setupTLS(getPem); err != nil {
 			return err
@@ -144,6 +208,92 @@ func (dbCfg MySQLConfig) BuildDSN() string {
 	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?%v", dbCfg.DatabaseUserName, dbCfg.DatabasePassword, dbCfg.DatabaseHost, dbCfg.DatabasePort, dbCfg.DatabaseSchemaName, strings.Join(arguments, "&"))
 }
 
+// ApplyPoolSettings sizes a connection pool from the configuration, blank settings fall back to their defaults
+func (dbCfg MySQLConfig) ApplyPoolSettings(db *sqlx.DB) error {
+	settings, err := dbCfg.poolSettings()
+	if err != nil {
+		return err
+	}
+	settings.apply(db)
+	return nil
+}
+
+// poolSettings is the sizing of a connection pool with the defaults filled in
+type poolSettings struct {
+	maxOpen  int
+	maxIdle  int
+	lifetime time.Duration
+	idleTime time.Duration
+}
+
+// poolSettings validates and parses the pool settings of the configuration, see Validate and ApplyPoolSettings.
+// An idle connection count left at its default is capped by the max open connections.
+func (dbCfg MySQLConfig) poolSettings() (poolSettings, error) {
+	settings := poolSettings{maxOpen: dbCfg.MaxOpenConns, maxIdle: dbCfg.MaxIdleConns, lifetime: DefaultConnMaxLifetime}
+	if settings.maxOpen < 0 {
+		return poolSettings{}, ErrInvalidMaxOpenConns
+	}
+	if settings.maxOpen == 0 {
+		settings.maxOpen = DefaultMaxOpenConns
+	}
+	if settings.maxIdle == 0 || settings.maxIdle == DefaultMaxIdleConns {
+		settings.maxIdle = defaultIdleConns(settings.maxOpen)
+	}
+	if settings.maxIdle < 0 || settings.maxIdle > settings.maxOpen {
+		return poolSettings{}, ErrInvalidMaxIdleConns
+	}
+	if len(dbCfg.ConnMaxLifetime) > 0 {
+		var err error
+		if settings.lifetime, err = time.ParseDuration(dbCfg.ConnMaxLifetime); err != nil || settings.lifetime < 0 {
+			return poolSettings{}, ErrInvalidConnMaxLifetime
+		}
+	}
+	if len(dbCfg.ConnMaxIdleTime) > 0 {
+		var err error
+		if settings.idleTime, err = time.ParseDuration(dbCfg.ConnMaxIdleTime); err != nil || settings.idleTime < 0 {
+			return poolSettings{}, ErrInvalidConnMaxIdleTime
+		}
+	}
+	return settings, nil
+}
+
+func (s poolSettings) apply(db *sqlx.DB) {
+	db.SetMaxOpenConns(s.maxOpen)
+	db.SetMaxIdleConns(s.maxIdle)
+	db.SetConnMaxLifetime(s.lifetime)
+	db.SetConnMaxIdleTime(s.idleTime)
+}
+
+// defaultIdleConns gets the default idle connection count, capped by the max open connections
+func defaultIdleConns(maxOpen int) int {
+	if maxOpen < DefaultMaxIdleConns {
+		return maxOpen
+	}
+	return DefaultMaxIdleConns
+}
+
+// BuildReplicaDSNs creates a DSN string for every replica host, replicas share every setting except for the host
+func (dbCfg MySQLConfig) BuildReplicaDSNs() []string {
+	var out []string
+	for k, replicaHost := range dbCfg.ReplicaHosts {
+		replicaCfg := dbCfg
+		replicaCfg.DatabaseHost, replicaCfg.DatabasePort = dbCfg.splitReplicaHost(replicaHost)
+		if k < len(dbCfg.replicaTLSIDs) {
+			replicaCfg.tlsID = dbCfg.replicaTLSIDs[k]
+		}
+		out = append(out, replicaCfg.BuildDSN())
+	}
+	return out
+}
+
+// splitReplicaHost splits a replica host into its host and port, defaulting to the primary's port
+func (dbCfg MySQLConfig) splitReplicaHost(replicaHost string) (string, string) {
+	if host, port, err := net.SplitHostPort(replicaHost); err == nil {
+		return host, port
+	}
+	return replicaHost, dbCfg.DatabasePort
+}
+
 // setupTLS configures TLS for the driver.
 // Optionally, a getPem function can be specified, if present, it will override it loading from the disk location in the config.
 func (dbCfg *MySQLConfig) setupTLS(getPem func() ([]byte, error)) error {
@@ -168,15 +318,31 @@ func (dbCfg *MySQLConfig) setupTLS(getPem func() ([]byte, error)) error {
 		return ErrInvalidCABundleProvided
 	}
 
-	id := atomic.AddInt32(&tlsIDCounter, 1)
-	dbCfg.tlsID = "mysql-tls-" + strconv.Itoa(int(id))
-	if err = mysql.RegisterTLSConfig(dbCfg.tlsID, &tls.Config{
-		ServerName: dbCfg.DatabaseHost,
-		RootCAs:    certPool,
-		MinVersion: tls.VersionTLS12,
-	}); err != nil {
+	if dbCfg.tlsID, err = registerTLS(dbCfg.DatabaseHost, certPool); err != nil {
 		return err
 	}
+	// Each replica needs its own registration since the server name has to match its host
+	for _, replicaHost := range dbCfg.ReplicaHosts {
+		host, _ := dbCfg.splitReplicaHost(replicaHost)
+		id, err := registerTLS(host, certPool)
+		if err != nil {
+			return err
+		}
+		dbCfg.replicaTLSIDs = append(dbCfg.replicaTLSIDs, id)
+	}
 
 	return nil
 }
+
+// registerTLS registers a TLS config with the driver for a given server name and returns its id
+func registerTLS(serverName string, certPool *x509.CertPool) (string, error) {
+	id := "mysql-tls-" + strconv.Itoa(int(atomic.AddInt32(&tlsIDCounter, 1)))
+	if err := mysql.RegisterTLSConfig(id, &tls.Config{
+		ServerName: serverName,
+		RootCAs:    certPool,
+		MinVersion: tls.VersionTLS12,
+	}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
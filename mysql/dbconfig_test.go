@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"github.com/stretchr/testify/require"
 	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
 	"os"
 	"path"
 	"testing"
@@ -24,6 +25,10 @@ func TestDefaultMySQLCfg(t *testing.T) {
 	require.Equal(t, false, cfg.TLSEnabled)
 	require.Equal(t, "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION,ANSI_QUOTES", cfg.SQLMode)
 	require.Equal(t, "REPEATABLE-READ", cfg.TXNIsolation)
+	require.Equal(t, 50, cfg.MaxOpenConns)
+	require.Equal(t, 25, cfg.MaxIdleConns)
+	require.Equal(t, "15m0s", cfg.ConnMaxLifetime)
+	require.Equal(t, "", cfg.ConnMaxIdleTime)
 }
 
 func TestMySQLConfig_Validate(t *testing.T) {
@@ -96,3 +101,90 @@ func TestMySQLConfig_SetupTLS(t *testing.T) {
 	})
 
 }
+
+func TestMySQLConfig_Replicas(t *testing.T) {
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseSchemaName = "test"
+	cfg.ReplicaHosts = []string{"localhost:3307", "localhost"}
+	require.NoError(t, cfg.Validate(nil))
+	dsns := cfg.BuildReplicaDSNs()
+	require.Len(t, dsns, 2)
+	require.Contains(t, dsns[0], "@tcp(localhost:3307)/test?")
+	require.Contains(t, dsns[1], "@tcp(localhost:3306)/test?")
+	cfg.ReplicaHosts = []string{""}
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidReplicaHost)
+	cfg.ReplicaHosts = []string{"localhost:lol"}
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidDatabasePort)
+	cfg.ReplicaHosts = []string{"donotresolve.localhost"}
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrUnreachableDatabaseHost)
+}
+
+func TestMySQLConfig_ReplicaConsistency(t *testing.T) {
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseSchemaName = "test"
+	cfg.ReplicaConsistency = "gtid"
+	cfg.ReplicaGTIDWaitTimeout = "250ms"
+	require.NoError(t, cfg.Validate(nil))
+	cfg.ReplicaConsistency = "strong"
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidReadConsistency)
+	cfg.ReplicaConsistency = "primary"
+	cfg.ReplicaGTIDWaitTimeout = "soon"
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidGTIDWaitTimeout)
+}
+
+func TestMySQLConfig_PoolSettings(t *testing.T) {
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseSchemaName = "test"
+	cfg.MaxOpenConns = 0
+	cfg.MaxIdleConns = 0
+	cfg.ConnMaxLifetime = ""
+	require.NoError(t, cfg.Validate(nil))
+	require.Equal(t, mysql.DefaultMaxOpenConns, cfg.MaxOpenConns)
+	require.Equal(t, mysql.DefaultMaxIdleConns, cfg.MaxIdleConns)
+	require.Equal(t, "15m0s", cfg.ConnMaxLifetime)
+	cfg.MaxOpenConns = 10
+	cfg.MaxIdleConns = 0
+	require.NoError(t, cfg.Validate(nil))
+	require.Equal(t, 10, cfg.MaxIdleConns)
+	defaults := mysql.DefaultMySQLCfg()
+	defaults.DatabaseSchemaName = "test"
+	defaults.MaxOpenConns = 10
+	require.NoError(t, defaults.Validate(nil), "the default idle connections are capped by a lower max")
+	require.Equal(t, 10, defaults.MaxIdleConns)
+	cfg.MaxOpenConns = -1
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidMaxOpenConns)
+	cfg.MaxOpenConns = 10
+	cfg.MaxIdleConns = 11
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidMaxIdleConns)
+	cfg.MaxIdleConns = 5
+	cfg.ConnMaxLifetime = "forever"
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidConnMaxLifetime)
+	cfg.ConnMaxLifetime = "1h"
+	cfg.ConnMaxIdleTime = "-1m"
+	require.ErrorIs(t, cfg.Validate(nil), mysql.ErrInvalidConnMaxIdleTime)
+	cfg.ConnMaxIdleTime = "5m"
+	require.NoError(t, cfg.Validate(nil))
+
+	db := internal.OpenFakeDB(t, "pool")
+	require.NoError(t, cfg.ApplyPoolSettings(db))
+	require.Equal(t, 10, db.Stats().MaxOpenConnections)
+	cfg.ConnMaxIdleTime = "later"
+	require.ErrorIs(t, cfg.ApplyPoolSettings(db), mysql.ErrInvalidConnMaxIdleTime)
+	cfg.ConnMaxIdleTime = ""
+	cfg.MaxIdleConns = 11
+	require.ErrorIs(t, cfg.ApplyPoolSettings(db), mysql.ErrInvalidMaxIdleConns, "pools are validated like the configuration")
+}
+
+func TestNewSceneProvider_InvalidPoolSettings(t *testing.T) {
+	// Nothing listens on the port, so the settings must be rejected before connecting
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseHost = "127.0.0.1"
+	cfg.DatabasePort = "1"
+	cfg.ConnMaxLifetime = "forever"
+	_, err := mysql.NewSceneProvider(cfg, nil)
+	require.ErrorIs(t, err, mysql.ErrInvalidConnMaxLifetime)
+	cfg.ConnMaxLifetime = ""
+	cfg.ConnMaxIdleTime = "a while"
+	_, err = mysql.NewSceneProvider(cfg, nil)
+	require.ErrorIs(t, err, mysql.ErrInvalidConnMaxIdleTime)
+}
@@ -0,0 +1,171 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// drainPollInterval is how often in-flight work is re-checked while draining
+const drainPollInterval = time.Millisecond * 10
+
+type trackedRows struct {
+	rows     rowCloser
+	location string
+}
+
+// ErrShuttingDown is returned by every operation of an instance handed out while its provider was shutting down,
+// and by committing a transaction the provider force closed (see WithShutdownTimeout)
+var ErrShuttingDown = errors.New("the database provider is shutting down")
+
+// inFlightState mirrors the transaction and rows of an instance so that they can be inspected (and force closed)
+// from another goroutine
+type inFlightState struct {
+	inTx atomic.Bool
+	// tx is only set for transactions the instance owns, a test transaction (see WithTestTx) is never force closed
+	tx   atomic.Pointer[sqlx.Tx]
+	rows atomic.Pointer[trackedRows]
+	// forceClosed is set once the provider rolled tx back, the instance ends its side on the next commit or rollback
+	forceClosed atomic.Bool
+}
+
+// InFlightWork describes work an instance still had open when its provider shut down
+type InFlightWork struct {
+	// Is a transaction still open?
+	InTx bool
+	// Where the rows that are still open were opened from, blank if there are none
+	OpenRowsAt string
+}
+
+func (w InFlightWork) String() string {
+	switch {
+	case w.InTx && len(w.OpenRowsAt) > 0:
+		return "open transaction with rows opened on " + w.OpenRowsAt
+	case w.InTx:
+		return "open transaction"
+	default:
+		return "rows opened on " + w.OpenRowsAt
+	}
+}
+
+// ShutdownError is returned when a provider could not drain its in-flight work before its shutdown timeout
+// or its pools failed to close. The work that was still in flight has been rolled back and closed.
+// It matches ShutdownErr with errors.Is.
+type ShutdownError struct {
+	// The provider's name, blank for the default provider
+	Provider string
+	// Work that was still in flight once the shutdown timeout passed, it was force closed
+	InFlight []InFlightWork
+	// Any error from closing the pools
+	CloseErr error
+}
+
+func (e *ShutdownError) Error() string {
+	buf := strings.Builder{}
+	buf.WriteString(ShutdownErr.Error())
+	if len(e.Provider) > 0 {
+		buf.WriteString(" [" + e.Provider + "]")
+	}
+	if len(e.InFlight) > 0 {
+		buf.WriteString(fmt.Sprintf(", %v instance(s) still in flight:", len(e.InFlight)))
+		for _, work := range e.InFlight {
+			buf.WriteString("\n\t" + work.String())
+		}
+	}
+	if e.CloseErr != nil {
+		buf.WriteString("\nclose error: " + e.CloseErr.Error())
+	}
+	return buf.String()
+}
+
+func (e *ShutdownError) Is(target error) bool {
+	return target == ShutdownErr
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.CloseErr
+}
+
+// instanceTracker keeps track of every instance a provider handed out that has not been closed yet
+type instanceTracker struct {
+	mu        sync.Mutex
+	draining  bool
+	instances map[*Instance]struct{}
+}
+
+func newInstanceTracker() *instanceTracker {
+	return &instanceTracker{instances: make(map[*Instance]struct{})}
+}
+
+// add starts tracking an instance, false is returned if the provider is draining and should not hand out instances
+func (t *instanceTracker) add(instance *Instance) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.instances[instance] = struct{}{}
+	return true
+}
+
+func (t *instanceTracker) remove(instance *Instance) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.instances, instance)
+}
+
+// inFlight lists the work open across every tracked instance
+func (t *instanceTracker) inFlight() []InFlightWork {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []InFlightWork
+	for instance := range t.instances {
+		work := InFlightWork{InTx: instance.inFlight.inTx.Load()}
+		if rows := instance.inFlight.rows.Load(); rows != nil && !rows.rows.IsClosed() {
+			work.OpenRowsAt = rows.location
+		}
+		if work.InTx || len(work.OpenRowsAt) > 0 {
+			out = append(out, work)
+		}
+	}
+	return out
+}
+
+// drain stops new instances from being handed out and waits until no transactions or rows are open,
+// any work still in flight once the deadline passes is returned.
+func (t *instanceTracker) drain(deadline time.Time) []InFlightWork {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		work := t.inFlight()
+		if len(work) == 0 || !time.Now().Before(deadline) {
+			return work
+		}
+		<-ticker.C
+	}
+}
+
+// forceClose rolls back the transactions and closes the rows still open across every tracked instance.
+// Both are safe to close from another goroutine, the instances get sql.ErrTxDone or closed rows on their next use
+// until the transaction is ended, committing it fails with ErrShuttingDown.
+func (t *instanceTracker) forceClose() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for instance := range t.instances {
+		if rows := instance.inFlight.rows.Load(); rows != nil {
+			_ = rows.rows.Close()
+		}
+		if tx := instance.inFlight.tx.Swap(nil); tx != nil && tx.Rollback() == nil {
+			instance.inFlight.inTx.Store(false)
+			instance.inFlight.forceClosed.Store(true)
+		}
+	}
+}
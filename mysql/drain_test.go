@@ -0,0 +1,98 @@
+package mysql_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	deadlinewg "github.com/weisbartb/deadline-wg"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestProvider_OnFactoryUnmount(t *testing.T) {
+	setup := func(t *testing.T, name string) (mysql.Provider, *scene.Factory) {
+		factory, provider := internal.NewSceneFactory(t, nil,
+			mysql.WithDB(internal.OpenFakeDB(t, name)),
+			mysql.WithCloseOnShutdown(true),
+			mysql.WithShutdownTimeout(time.Millisecond*100),
+		)
+		return provider, factory
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		provider, factory := setup(t, "clean")
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		_, err = mysql.GetManagedDatabaseInstance(ctx).Exec("INSERT INTO test_table VALUES ()")
+		require.NoError(t, err)
+		require.NoError(t, provider.OnFactoryUnmount(factory))
+		ctx.Complete()
+	})
+	t.Run("drains", func(t *testing.T) {
+		provider, factory := setup(t, "drains")
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		instance := mysql.GetManagedDatabaseInstance(ctx)
+		require.NoError(t, instance.BeginTx(nil))
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			_ = instance.Commit()
+			ctx.Complete()
+		}()
+		require.NoError(t, provider.OnFactoryUnmount(factory))
+	})
+	t.Run("times out", func(t *testing.T) {
+		provider, factory := setup(t, "timeout")
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		instance := mysql.GetManagedDatabaseInstance(ctx)
+		require.NoError(t, instance.BeginTx(nil))
+		_, err = instance.Query("SELECT source")
+		require.NoError(t, err)
+		idleCtx, err := factory.NewCtx()
+		require.NoError(t, err)
+		defer idleCtx.Complete()
+
+		err = provider.OnFactoryUnmount(factory)
+		require.ErrorIs(t, err, mysql.ShutdownErr)
+		var shutdownErr *mysql.ShutdownError
+		require.True(t, errors.As(err, &shutdownErr))
+		require.Len(t, shutdownErr.InFlight, 1)
+		require.True(t, shutdownErr.InFlight[0].InTx)
+		require.Contains(t, shutdownErr.InFlight[0].OpenRowsAt, "drain_test.go")
+		require.ErrorIs(t, shutdownErr.CloseErr, deadlinewg.ErrTimeout, "the drain used up the whole timeout")
+		require.Contains(t, err.Error(), "1 instance(s) still in flight")
+		statements := internal.FakeDBStatements("timeout")
+		require.Equal(t, "ROLLBACK", statements[len(statements)-1], "work still in flight is force closed")
+		_, err = instance.Exec("INSERT INTO test_table VALUES ()")
+		require.ErrorIs(t, err, sql.ErrTxDone, "statements do not fall out of the force closed transaction")
+		require.ErrorIs(t, instance.Commit(), mysql.ErrShuttingDown)
+		require.False(t, instance.InTx())
+		require.ErrorIs(t, instance.Rollback(), mysql.ErrNoActiveTransaction)
+
+		// Instances handed out once draining started fail every operation
+		spawned, err := ctx.Spawn(scene.RunForever)
+		require.NoError(t, err)
+		closed := mysql.GetManagedDatabaseInstance(spawned)
+		require.NotNil(t, closed)
+		_, err = closed.Exec("INSERT INTO test_table VALUES ()")
+		require.ErrorIs(t, err, mysql.ErrShuttingDown)
+		require.ErrorIs(t, closed.SpawnChild().QueryRow("SELECT source").Err(), mysql.ErrShuttingDown)
+		spawned.Complete()
+		ctx.Complete()
+	})
+	t.Run("deadline is shared", func(t *testing.T) {
+		provider, factory := setup(t, "deadline")
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		require.NoError(t, mysql.GetManagedDatabaseInstance(ctx).BeginTx(nil))
+		started := time.Now()
+		require.ErrorIs(t, provider.OnFactoryUnmount(factory), mysql.ShutdownErr)
+		require.Less(t, time.Since(started), time.Millisecond*190, "closing the pools does not get a second timeout")
+		ctx.Complete()
+	})
+}
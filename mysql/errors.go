@@ -2,15 +2,129 @@ package mysql
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"strings"
+
 	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 )
 
 const (
-	ErrCodeDuplicateKey uint16 = 1062
-	ErrCodeDeadlockCode uint16 = 1213
+	ErrCodeDuplicateKey       uint16 = 1062
+	ErrCodeLockWaitTimeout    uint16 = 1205
+	ErrCodeDeadlockCode       uint16 = 1213
+	ErrCodeTooManyConnections uint16 = 1040
+	ErrCodeUnknownTableDrop   uint16 = 1051
+	ErrCodeUnknownColumn      uint16 = 1054
+	ErrCodeUnknownTable       uint16 = 1146
+	ErrCodeOptionPrevents     uint16 = 1290
+	ErrCodeQueryInterrupted   uint16 = 1317
+	ErrCodeDataTooLong        uint16 = 1406
+	ErrCodeRowIsReferenced    uint16 = 1451
+	ErrCodeNoReferencedRow    uint16 = 1452
+	ErrCodeReadOnlyMode       uint16 = 1836
+	ErrCodeQueryTimeout       uint16 = 3024
+	ErrCodeServerGone         uint16 = 2006
+	ErrCodeServerLost         uint16 = 2013
+	ErrCodeRowIsReferencedOld uint16 = 1217
+	ErrCodeNoReferencedRowOld uint16 = 1216
+)
+
+// ErrorKind is the class of failure an error represents, see ClassifyError
+type ErrorKind int
+
+const (
+	// ErrorKindNone is the kind of a nil error
+	ErrorKindNone ErrorKind = iota
+	// ErrorKindOther is any error that is not classified below
+	ErrorKindOther
+	ErrorKindDuplicateKey
+	ErrorKindDeadlock
+	ErrorKindLockWaitTimeout
+	// ErrorKindForeignKey covers both deleting a referenced row (1451) and referencing a missing row (1452)
+	ErrorKindForeignKey
+	ErrorKindDataTooLong
+	// ErrorKindReadOnly is a write sent to a read-only server (1836, or 1290 caused by --read-only or
+	// --super-read-only), usually a replica or a failover
+	ErrorKindReadOnly
+	ErrorKindTooManyConnections
+	// ErrorKindLostConnection is a connection that dropped mid statement (2006, 2013 or a bad connection)
+	ErrorKindLostConnection
+	ErrorKindQueryInterrupted
+	// ErrorKindQueryTimeout is a statement that exceeded max_execution_time (3024)
+	ErrorKindQueryTimeout
+	ErrorKindUnknownColumn
+	ErrorKindUnknownTable
 )
 
+var errorKindNames = map[ErrorKind]string{
+	ErrorKindNone:               "none",
+	ErrorKindOther:              "other",
+	ErrorKindDuplicateKey:       "duplicate_key",
+	ErrorKindDeadlock:           "deadlock",
+	ErrorKindLockWaitTimeout:    "lock_wait_timeout",
+	ErrorKindForeignKey:         "foreign_key",
+	ErrorKindDataTooLong:        "data_too_long",
+	ErrorKindReadOnly:           "read_only",
+	ErrorKindTooManyConnections: "too_many_connections",
+	ErrorKindLostConnection:     "lost_connection",
+	ErrorKindQueryInterrupted:   "query_interrupted",
+	ErrorKindQueryTimeout:       "query_timeout",
+	ErrorKindUnknownColumn:      "unknown_column",
+	ErrorKindUnknownTable:       "unknown_table",
+}
+
+func (k ErrorKind) String() string {
+	if name, found := errorKindNames[k]; found {
+		return name
+	}
+	return "other"
+}
+
+var errorKindsByCode = map[uint16]ErrorKind{
+	ErrCodeDuplicateKey:       ErrorKindDuplicateKey,
+	ErrCodeDeadlockCode:       ErrorKindDeadlock,
+	ErrCodeLockWaitTimeout:    ErrorKindLockWaitTimeout,
+	ErrCodeRowIsReferenced:    ErrorKindForeignKey,
+	ErrCodeNoReferencedRow:    ErrorKindForeignKey,
+	ErrCodeRowIsReferencedOld: ErrorKindForeignKey,
+	ErrCodeNoReferencedRowOld: ErrorKindForeignKey,
+	ErrCodeDataTooLong:        ErrorKindDataTooLong,
+	ErrCodeReadOnlyMode:       ErrorKindReadOnly,
+	ErrCodeTooManyConnections: ErrorKindTooManyConnections,
+	ErrCodeServerGone:         ErrorKindLostConnection,
+	ErrCodeServerLost:         ErrorKindLostConnection,
+	ErrCodeQueryInterrupted:   ErrorKindQueryInterrupted,
+	ErrCodeQueryTimeout:       ErrorKindQueryTimeout,
+	ErrCodeUnknownColumn:      ErrorKindUnknownColumn,
+	ErrCodeUnknownTable:       ErrorKindUnknownTable,
+	ErrCodeUnknownTableDrop:   ErrorKindUnknownTable,
+}
+
+// ClassifyError gets the kind of failure err represents, err may be wrapped
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindNone
+	}
+	code := GetErrorCode(err)
+	if kind, found := errorKindsByCode[code]; found {
+		return kind
+	}
+	if code == ErrCodeOptionPrevents {
+		// 1290 is raised for any option that prevents a statement (--secure-file-priv, --skip-grant-tables, ...),
+		// only the read-only options mean the server does not take writes
+		msg := getMySQLError(err).Message
+		if strings.Contains(msg, "--read-only") || strings.Contains(msg, "--super-read-only") {
+			return ErrorKindReadOnly
+		}
+	}
+	// The driver reports dropped connections itself rather than through a server error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return ErrorKindLostConnection
+	}
+	return ErrorKindOther
+}
+
 func getMySQLError(err error) *mysql.MySQLError {
 	var out *mysql.MySQLError
 	if errors.As(err, &out) {
@@ -41,6 +155,60 @@ func IsDeadlocked(err error) bool {
 	}
 	return false
 }
+func IsLockWaitTimeout(err error) bool {
+	if mysqlErr := getMySQLError(err); mysqlErr != nil {
+		return mysqlErr.Number == ErrCodeLockWaitTimeout
+	}
+	return false
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return ClassifyError(err) == ErrorKindForeignKey
+}
+func IsDataTooLong(err error) bool {
+	return ClassifyError(err) == ErrorKindDataTooLong
+}
+func IsReadOnly(err error) bool {
+	return ClassifyError(err) == ErrorKindReadOnly
+}
+func IsTooManyConnections(err error) bool {
+	return ClassifyError(err) == ErrorKindTooManyConnections
+}
+func IsLostConnection(err error) bool {
+	return ClassifyError(err) == ErrorKindLostConnection
+}
+func IsQueryInterrupted(err error) bool {
+	return ClassifyError(err) == ErrorKindQueryInterrupted
+}
+func IsQueryTimeout(err error) bool {
+	return ClassifyError(err) == ErrorKindQueryTimeout
+}
+func IsUnknownColumn(err error) bool {
+	return ClassifyError(err) == ErrorKindUnknownColumn
+}
+func IsUnknownTable(err error) bool {
+	return ClassifyError(err) == ErrorKindUnknownTable
+}
+
+// IsRetryable checks if the transaction that failed with err can be replayed as is, this is the case for
+// deadlocks and lock wait timeouts (see RequireTxRetry)
+func IsRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorKindDeadlock, ErrorKindLockWaitTimeout:
+		return true
+	}
+	return false
+}
+
+// IsTransient checks if err is likely to go away on its own, such as a lost connection, a full connection pool on the
+// server or a failover that left the primary read-only. Anything retryable is also transient.
+func IsTransient(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorKindTooManyConnections, ErrorKindLostConnection, ErrorKindReadOnly:
+		return true
+	}
+	return IsRetryable(err)
+}
 
 func respErrorHandler(err error) error {
 	if err == nil {
@@ -2,11 +2,13 @@ package mysql_test
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	md "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/require"
 	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/stack"
 	"testing"
 )
 
@@ -44,3 +46,68 @@ func TestIsDeadlocked(t *testing.T) {
 	require.Equal(t, true, mysql.IsDeadlocked(err))
 	require.Equal(t, false, mysql.IsDeadlocked(errors.New("test")))
 }
+
+func TestIsLockWaitTimeout(t *testing.T) {
+	err := &md.MySQLError{
+		Number:   mysql.ErrCodeLockWaitTimeout,
+		SQLState: [5]byte{},
+		Message:  "Lock wait timeout exceeded; try restarting transaction",
+	}
+	require.Equal(t, true, mysql.IsLockWaitTimeout(err))
+	require.Equal(t, false, mysql.IsLockWaitTimeout(errors.New("test")))
+}
+
+func TestClassifyError(t *testing.T) {
+	serverErr := func(code uint16) error {
+		return &md.MySQLError{Number: code}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		kind      mysql.ErrorKind
+		predicate func(error) bool
+		retryable bool
+		transient bool
+	}{
+		{name: "nil", err: nil, kind: mysql.ErrorKindNone},
+		{name: "other", err: errors.New("test"), kind: mysql.ErrorKindOther},
+		{name: "unclassified code", err: serverErr(1064), kind: mysql.ErrorKindOther},
+		{name: "duplicate key", err: serverErr(1062), kind: mysql.ErrorKindDuplicateKey, predicate: mysql.IsDuplicateKeyError},
+		{name: "deadlock", err: serverErr(1213), kind: mysql.ErrorKindDeadlock, predicate: mysql.IsDeadlocked, retryable: true, transient: true},
+		{name: "lock wait", err: serverErr(1205), kind: mysql.ErrorKindLockWaitTimeout, predicate: mysql.IsLockWaitTimeout, retryable: true, transient: true},
+		{name: "row referenced", err: serverErr(1451), kind: mysql.ErrorKindForeignKey, predicate: mysql.IsForeignKeyViolation},
+		{name: "no referenced row", err: serverErr(1452), kind: mysql.ErrorKindForeignKey, predicate: mysql.IsForeignKeyViolation},
+		{name: "data too long", err: serverErr(1406), kind: mysql.ErrorKindDataTooLong, predicate: mysql.IsDataTooLong},
+		{name: "option prevents", err: serverErr(1290), kind: mysql.ErrorKindOther},
+		{name: "secure file priv", err: &md.MySQLError{Number: 1290, Message: "The MySQL server is running with the --secure-file-priv option so it cannot execute this statement"}, kind: mysql.ErrorKindOther},
+		{name: "read only option", err: &md.MySQLError{Number: 1290, Message: "The MySQL server is running with the --read-only option so it cannot execute this statement"}, kind: mysql.ErrorKindReadOnly, predicate: mysql.IsReadOnly, transient: true},
+		{name: "super read only option", err: &md.MySQLError{Number: 1290, Message: "The MySQL server is running with the --super-read-only option so it cannot execute this statement"}, kind: mysql.ErrorKindReadOnly, predicate: mysql.IsReadOnly, transient: true},
+		{name: "read only mode", err: serverErr(1836), kind: mysql.ErrorKindReadOnly, predicate: mysql.IsReadOnly, transient: true},
+		{name: "too many connections", err: serverErr(1040), kind: mysql.ErrorKindTooManyConnections, predicate: mysql.IsTooManyConnections, transient: true},
+		{name: "server gone", err: serverErr(2006), kind: mysql.ErrorKindLostConnection, predicate: mysql.IsLostConnection, transient: true},
+		{name: "server lost", err: serverErr(2013), kind: mysql.ErrorKindLostConnection, predicate: mysql.IsLostConnection, transient: true},
+		{name: "bad connection", err: driver.ErrBadConn, kind: mysql.ErrorKindLostConnection, predicate: mysql.IsLostConnection, transient: true},
+		{name: "invalid connection", err: md.ErrInvalidConn, kind: mysql.ErrorKindLostConnection, predicate: mysql.IsLostConnection, transient: true},
+		{name: "interrupted", err: serverErr(1317), kind: mysql.ErrorKindQueryInterrupted, predicate: mysql.IsQueryInterrupted},
+		{name: "max execution time", err: serverErr(3024), kind: mysql.ErrorKindQueryTimeout, predicate: mysql.IsQueryTimeout},
+		{name: "unknown column", err: serverErr(1054), kind: mysql.ErrorKindUnknownColumn, predicate: mysql.IsUnknownColumn},
+		{name: "unknown table", err: serverErr(1146), kind: mysql.ErrorKindUnknownTable, predicate: mysql.IsUnknownTable},
+		{name: "unknown table on drop", err: serverErr(1051), kind: mysql.ErrorKindUnknownTable, predicate: mysql.IsUnknownTable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, err := range []error{tt.err, fmt.Errorf("wrapped: %w", tt.err), stack.Trace(tt.err)} {
+				if tt.err == nil && err != nil {
+					continue
+				}
+				require.Equal(t, tt.kind, mysql.ClassifyError(err))
+				require.Equal(t, tt.retryable, mysql.IsRetryable(err))
+				require.Equal(t, tt.transient, mysql.IsTransient(err))
+				if tt.predicate != nil {
+					require.True(t, tt.predicate(err))
+				}
+			}
+		})
+	}
+	require.Equal(t, "lock_wait_timeout", mysql.ErrorKindLockWaitTimeout.String())
+}
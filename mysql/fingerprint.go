@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"regexp"
+	"strings"
+)
+
+var placeholderListExp = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+var placeholderGroupsExp = regexp.MustCompile(`\(\?\+\)(?:\s*,\s*\(\?\+\))+`)
+
+// NormalizeQuery reduces a statement to its shape so that it can be safely logged and grouped.
+// Comments are removed, whitespace is collapsed, string and numeric literals are replaced with ? and lists of
+// placeholders (IN lists, multi-row VALUES) are collapsed to (?+).
+// Double-quoted values are treated as identifiers since the default SQL mode uses ANSI_QUOTES.
+func NormalizeQuery(query string) string {
+	buf := strings.Builder{}
+	buf.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "-- ")):
+			// Line comments run until the end of the line
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			space = true
+			continue
+		}
+		if space && buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		space = false
+		switch {
+		case c == '\'':
+			i = skipQuoted(query, i)
+			buf.WriteByte('?')
+		case c == '`' || c == '"':
+			end := skipQuoted(query, i)
+			buf.WriteString(query[i : end+1])
+			i = end
+		case isDigit(c) && (i == 0 || !isIdentChar(query[i-1])):
+			for i+1 < len(query) && (isIdentChar(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			buf.WriteByte('?')
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	out := placeholderListExp.ReplaceAllString(buf.String(), "(?+)")
+	return placeholderGroupsExp.ReplaceAllString(out, "(?+)")
+}
+
+// skipQuoted gets the index of the quote closing the quoted section starting at start.
+// Quotes are escaped by doubling them, backslash escapes are honored outside of identifiers.
+func skipQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query) - 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
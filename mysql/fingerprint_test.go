@@ -0,0 +1,53 @@
+package mysql_test
+
+import (
+	"testing"
+
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Whitespace",
+			query: "SELECT  *\n\tFROM test_kvp\n",
+			want:  "SELECT * FROM test_kvp",
+		},
+		{
+			name:  "Literals",
+			query: "SELECT * FROM test_table2 WHERE id = 12 AND `key` = 'it''s' AND val = 'a\\'b' AND score > 1.5e3",
+			want:  "SELECT * FROM test_table2 WHERE id = ? AND `key` = ? AND val = ? AND score > ?",
+		},
+		{
+			name:  "Identifiers",
+			query: "SELECT \"col1\" FROM `table 1`",
+			want:  "SELECT \"col1\" FROM `table 1`",
+		},
+		{
+			name:  "Comments",
+			query: "/* route='/users' */ SELECT 1 -- trailing\n FROM dual # mysql style",
+			want:  "SELECT ? FROM dual",
+		},
+		{
+			name:  "Lists",
+			query: "SELECT * FROM test_kvp WHERE `key` IN (?, ?, ?) OR `key` IN ('a','b')",
+			want:  "SELECT * FROM test_kvp WHERE `key` IN (?+) OR `key` IN (?+)",
+		},
+		{
+			name:  "Multi-row values",
+			query: "INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2'), (?, ?)",
+			want:  "INSERT INTO test_kvp (`key`,`val`) VALUES(?+)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mysql.NormalizeQuery(tt.query); got != tt.want {
+				t.Errorf("NormalizeQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package mysql
+
+// hookMark records how many callbacks were registered when a savepoint was taken
+type hookMark struct {
+	onCommit   int
+	onRollback int
+}
+
+// AfterCommit registers fn to run once the active transaction commits, it is discarded if the transaction rolls back.
+// If no transaction is active fn runs immediately.
+func (d *Instance) AfterCommit(fn func()) {
+	if d.tx == nil {
+		fn()
+		return
+	}
+	d.onCommit = append(d.onCommit, fn)
+}
+
+// AfterRollback registers fn to run if the active transaction (or the nested RequireTx it was registered in) is
+// rolled back. err is the error that caused the rollback, or nil when Rollback was called directly.
+// If no transaction is active fn is discarded since there is nothing to roll back.
+func (d *Instance) AfterRollback(fn func(err error)) {
+	if d.tx == nil {
+		return
+	}
+	d.onRollback = append(d.onRollback, fn)
+}
+
+func (d *Instance) fireCommit() {
+	hooks := d.onCommit
+	d.onCommit = nil
+	d.onRollback = nil
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+func (d *Instance) fireRollback(err error) {
+	hooks := d.onRollback
+	d.onCommit = nil
+	d.onRollback = nil
+	for _, fn := range hooks {
+		fn(err)
+	}
+}
+
+func (d *Instance) hookMark() hookMark {
+	return hookMark{onCommit: len(d.onCommit), onRollback: len(d.onRollback)}
+}
+
+// rewindHooks drops callbacks registered after mark once a savepoint was rolled back, firing the rollback ones
+func (d *Instance) rewindHooks(mark hookMark, err error) {
+	hooks := d.onRollback[mark.onRollback:]
+	d.onCommit = d.onCommit[:mark.onCommit]
+	d.onRollback = d.onRollback[:mark.onRollback:mark.onRollback]
+	for _, fn := range hooks {
+		fn(err)
+	}
+}
@@ -0,0 +1,87 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+func TestInstance_AfterCommitWithoutTx(t *testing.T) {
+	instance := mysql.NewInstance(context.Background(), nil)
+	var committed, rolledBack bool
+	instance.AfterCommit(func() {
+		committed = true
+	})
+	instance.AfterRollback(func(err error) {
+		rolledBack = true
+	})
+	require.True(t, committed)
+	require.False(t, rolledBack)
+}
+
+func TestInstance_AfterCommit(t *testing.T) {
+	instance := setupInstance(t)
+	var events []string
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		db.AfterCommit(func() {
+			events = append(events, "outer")
+		})
+		db.AfterRollback(func(err error) {
+			events = append(events, "outer rollback")
+		})
+		_ = db.RequireTx(func(db *mysql.Instance) error {
+			db.AfterCommit(func() {
+				events = append(events, "discarded")
+			})
+			db.AfterRollback(func(err error) {
+				events = append(events, "inner rollback: "+err.Error())
+			})
+			return errors.New("hi")
+		})
+		require.NoError(t, db.RequireTx(func(db *mysql.Instance) error {
+			db.AfterCommit(func() {
+				events = append(events, "inner")
+			})
+			return nil
+		}))
+		require.Equal(t, []string{"inner rollback: hi"}, events)
+		return nil
+	}))
+	require.Equal(t, []string{"inner rollback: hi", "outer", "inner"}, events)
+}
+
+func TestInstance_AfterRollback(t *testing.T) {
+	instance := setupInstance(t)
+	var causes []error
+	err := instance.RequireTx(func(db *mysql.Instance) error {
+		db.AfterCommit(func() {
+			t.Fatal("commit hook should not run")
+		})
+		db.AfterRollback(func(err error) {
+			causes = append(causes, err)
+		})
+		return errors.New("hi")
+	})
+	require.EqualError(t, err, "hi")
+	require.Len(t, causes, 1)
+	require.EqualError(t, causes[0], "hi")
+
+	require.NoError(t, instance.BeginTx(nil))
+	instance.AfterRollback(func(err error) {
+		causes = append(causes, err)
+	})
+	require.NoError(t, instance.Rollback())
+	require.Len(t, causes, 2)
+	require.Nil(t, causes[1])
+
+	require.NoError(t, instance.BeginTx(nil))
+	instance.AfterRollback(func(err error) {
+		causes = append(causes, err)
+	})
+	require.Empty(t, instance.Close())
+	require.Len(t, causes, 3)
+	require.ErrorIs(t, causes[2], mysql.ErrClosedWithActiveTransaction)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/weisbartb/stack"
 	"runtime"
 	"strconv"
+	"time"
 
 	// Needs to be imported for side-effects, without it this will fail to work properly
 	_ "github.com/go-sql-driver/mysql"
@@ -44,39 +45,137 @@ type rowCloser interface {
 }
 
 type Instance struct {
-	ctx                context.Context
-	db                 *sqlx.DB
+	ctx context.Context
+	db  *sqlx.DB
+	// The transaction and open rows, shared with the tagged views of this instance (see WithTags)
+	*session
+	// Reads outside a transaction are balanced across replicas unless the instance was pinned to the primary
+	replicas        *ReplicaPool
+	pinnedToPrimary bool
+	// Tracks writes to the primary so reads can stay consistent with them, see ReadConsistency.
+	// Shared with children since they belong to the same scene, txWrote only covers this instance's transaction.
+	writes *writeState
+	// Every operation passes through the interceptors, see Interceptor
+	interceptors []Interceptor
+	// Transactions and unclosed rows are only measured when the provider has a collector, see WithMetrics
+	metrics  MetricsCollector
+	provider string
+	// Shared with children since they belong to the same scene
+	stats *queryStats
+	// Statements are prefixed with a sqlcommenter comment built from these, see WithSQLCommenter
+	commenter *SQLCommenterOptions
+	tags      []Tag
+	// Errors are wrapped in a *QueryError when this is set, see WithQueryErrors
+	queryErrors *QueryErrorOptions
+	// Transactions become savepoints of a transaction owned by a test when this is set, see WithTestTx
+	testTx *testTx
+	// Every operation fails with this when set, see ErrShuttingDown
+	unavailable error
+}
+
+// session holds the state an instance keeps between operations
+type session struct {
 	tx                 *sqlx.Tx
 	children           []*Instance
 	currentOpenRows    rowCloser
 	lastOpenedLocation string
+	// savepoints is the depth of nested RequireTx calls currently held open inside tx
+	savepoints int
+	// Callbacks waiting on the outcome of the active transaction
+	onCommit   []func()
+	onRollback []func(err error)
+	// unitOfWork lazily opens a transaction on the first write that lives until the scene completes
+	unitOfWork bool
+	txWrote    bool
+	txStarted  time.Time
+	// The savepoint of the test transaction standing in for tx, see WithTestTx
+	testSavepoint string
+	// A goroutine safe view of the work in flight, used while draining a provider
+	inFlight inFlightState
 }
 
 var ErrRowsNotClosed = errors.New("rows were not closed on active connection")
 var ErrNoActiveTransaction = errors.New("no active transaction is present")
 var ErrTransactionAlreadyStarted = errors.New("transaction has already started")
+var ErrNestedTransactionActive = errors.New("nested transaction is still active")
+var ErrClosedWithActiveTransaction = errors.New("instance was closed with an active transaction")
 
 func NewInstance(ctx context.Context, db *sqlx.DB) *Instance {
-	return &Instance{ctx: ctx, db: db}
+	return &Instance{ctx: ctx, db: db, session: &session{}, stats: &queryStats{}, writes: &writeState{}}
 }
 
 func (d *Instance) SpawnChild() *Instance {
-	i := &Instance{ctx: d.ctx, db: d.db}
+	i := d.derive(d.ctx)
+	i.stats = d.stats
+	i.writes = d.writes
 	d.children = append(d.children, i)
 	return i
 }
 
 // Isolate creates a new connection instance no longer attached to the context
 func (d *Instance) Isolate() *Instance {
-	i := &Instance{ctx: context.Background(), db: d.db}
+	i := d.derive(context.Background())
+	i.stats = &queryStats{}
+	i.writes = &writeState{}
 	return i
 }
 
-// Raw gets the underlying SQL connection
+// derive creates an instance with its own transaction state that shares this instance's pools and instrumentation
+func (d *Instance) derive(ctx context.Context) *Instance {
+	return &Instance{
+		ctx:             ctx,
+		db:              d.db,
+		session:         &session{},
+		replicas:        d.replicas,
+		pinnedToPrimary: d.pinnedToPrimary,
+		interceptors:    d.interceptors,
+		metrics:         d.metrics,
+		provider:        d.provider,
+		commenter:       d.commenter,
+		tags:            d.tags,
+		queryErrors:     d.queryErrors,
+		testTx:          d.testTx,
+		unavailable:     d.unavailable,
+	}
+}
+
+// Raw gets the underlying SQL connection to the primary
 func (d *Instance) Raw() *sqlx.DB {
 	return d.db
 }
 
+// Primary gets a child instance (see SpawnChild) pinned to the primary, reads made through it are sent there instead
+// of a replica. This is for reads that can not tolerate replication lag, this instance keeps using the replicas.
+// Inside a transaction every read already goes to the primary, so this instance is returned as is.
+func (d *Instance) Primary() *Instance {
+	if d.tx != nil || d.pinnedToPrimary {
+		return d
+	}
+	i := d.SpawnChild()
+	i.pinnedToPrimary = true
+	return i
+}
+
+// reader gets the pool that reads outside a transaction are sent to,
+// falling back to the primary if there are no healthy replicas.
+func (d *Instance) reader() *sqlx.DB {
+	if d.replicas == nil || d.pinnedToPrimary {
+		return d.db
+	}
+	if d.writes.hasWritten() {
+		switch d.replicas.Consistency {
+		case PrimaryAfterWrite:
+			return d.db
+		case WaitForGTID:
+			return d.caughtUpReplica()
+		}
+	}
+	if db := d.replicas.next(); db != nil {
+		return db
+	}
+	return d.db
+}
+
 // DriverName returns the name of the underpinned driver
 func (d *Instance) DriverName() string {
 	return d.db.DriverName()
@@ -102,64 +201,114 @@ func (d *Instance) Query(query string, args ...any) (*Rows, error) {
 		return nil, err
 	}
 	var rows *sql.Rows
-	var err error
-	if d.tx != nil {
-		rows, err = d.tx.QueryContext(d.ctx, query, args...)
-	} else {
-		rows, err = d.db.QueryContext(d.ctx, query, args...)
-	}
-	err = respErrorHandler(err)
+	stmt := &Statement{Op: OpQuery, Query: query, Args: args}
+	err := d.run(stmt, func(ctx context.Context, stmt *Statement) (err error) {
+		if tx := d.activeTx(); tx != nil {
+			rows, err = tx.QueryContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			rows, err = d.reader().QueryContext(ctx, stmt.Query, stmt.Args...)
+		}
+		return err
+	})
 	if err != nil {
+		if rows != nil {
+			// An interceptor failed the query after it ran
+			_ = rows.Close()
+		}
 		return nil, err
 	}
 	out := &Rows{
-		Rows: rows,
+		Rows:  rows,
+		stats: d.stats,
+		errs:  resultErrors{instance: d, stmt: stmt},
 	}
-	return out, d.rowKeeper(out)
+	return out, d.rowKeeper(out, 1)
 }
 
 // Queryx runs a sqlx query command
 func (d *Instance) Queryx(query string, args ...any) (*Rowsx, error) {
+	return d.queryx(1, query, args...)
+}
+
+// queryx runs Queryx, skip is the number of frames between queryx and the caller the open rows are attributed to
+func (d *Instance) queryx(skip int, query string, args ...any) (*Rowsx, error) {
 	if err := d.unclosedCheck(); err != nil {
 		return nil, err
 	}
 	var rows *sqlx.Rows
-	var err error
-	if d.tx != nil {
-		rows, err = d.tx.QueryxContext(d.ctx, query, args...)
-	} else {
-		rows, err = d.db.QueryxContext(d.ctx, query, args...)
-	}
-	err = respErrorHandler(err)
+	stmt := &Statement{Op: OpQueryx, Query: query, Args: args}
+	err := d.run(stmt, func(ctx context.Context, stmt *Statement) (err error) {
+		if tx := d.activeTx(); tx != nil {
+			rows, err = tx.QueryxContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			rows, err = d.reader().QueryxContext(ctx, stmt.Query, stmt.Args...)
+		}
+		return err
+	})
 	if err != nil {
+		if rows != nil {
+			_ = rows.Close()
+		}
 		return nil, err
 	}
 	out := &Rowsx{
-		Rows: rows,
+		Rows:  rows,
+		stats: d.stats,
+		errs:  resultErrors{instance: d, stmt: stmt},
 	}
-	return out, d.rowKeeper(out)
+	return out, d.rowKeeper(out, skip+1)
 }
 
 // QueryRowx see sqlx.QueryRowx
 func (d *Instance) QueryRowx(query string, args ...any) *Rowx {
 	if d.currentOpenRows != nil && !d.currentOpenRows.IsClosed() {
+		d.observeUnclosedRows()
 		return &Rowx{err: ErrRowsNotClosed}
 	}
-	if d.tx != nil {
-		return &Rowx{Row: d.tx.QueryRowxContext(d.ctx, query, args...)}
+	var row *sqlx.Row
+	stmt := &Statement{Op: OpQueryRowx, Query: query, Args: args}
+	err := d.run(stmt, func(ctx context.Context, stmt *Statement) error {
+		if tx := d.activeTx(); tx != nil {
+			row = tx.QueryRowxContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			row = d.reader().QueryRowxContext(ctx, stmt.Query, stmt.Args...)
+		}
+		return row.Err()
+	})
+	if err != nil {
+		if row != nil {
+			// An interceptor failed the query after it ran, scanning nothing closes the row and releases its connection
+			_ = row.Scan()
+		}
+		return &Rowx{err: err}
 	}
-	return &Rowx{Row: d.db.QueryRowxContext(d.ctx, query, args...)}
+	return &Rowx{Row: row, stats: d.stats, errs: resultErrors{instance: d, stmt: stmt}}
 }
 
 // QueryRow see sql.QueryRow
 func (d *Instance) QueryRow(query string, args ...any) *Row {
 	if d.currentOpenRows != nil && !d.currentOpenRows.IsClosed() {
+		d.observeUnclosedRows()
 		return &Row{err: ErrRowsNotClosed}
 	}
-	if d.tx != nil {
-		return &Row{Row: d.tx.QueryRowContext(d.ctx, query, args...)}
+	var row *sql.Row
+	stmt := &Statement{Op: OpQueryRow, Query: query, Args: args}
+	err := d.run(stmt, func(ctx context.Context, stmt *Statement) error {
+		if tx := d.activeTx(); tx != nil {
+			row = tx.QueryRowContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			row = d.reader().QueryRowContext(ctx, stmt.Query, stmt.Args...)
+		}
+		return row.Err()
+	})
+	if err != nil {
+		if row != nil {
+			// An interceptor failed the query after it ran, scanning nothing closes the row and releases its connection
+			_ = row.Scan()
+		}
+		return &Row{err: err}
 	}
-	return &Row{Row: d.db.QueryRowContext(d.ctx, query, args...)}
+	return &Row{Row: row, stats: d.stats, errs: resultErrors{instance: d, stmt: stmt}}
 }
 
 // Exec uses SQLx's Exec function
@@ -167,14 +316,22 @@ func (d *Instance) Exec(query string, args ...any) (sql.Result, error) {
 	if err := d.unclosedCheck(); err != nil {
 		return nil, err
 	}
+	if err := d.beginUnitOfWork(); err != nil {
+		return nil, err
+	}
 	var res sql.Result
-	var err error
-	if d.tx != nil {
-		res, err = d.tx.ExecContext(d.ctx, query, args...)
-	} else {
-		res, err = d.db.ExecContext(d.ctx, query, args...)
+	err := d.run(&Statement{Op: OpExec, Query: query, Args: args}, func(ctx context.Context, stmt *Statement) (err error) {
+		if tx := d.activeTx(); tx != nil {
+			res, err = tx.ExecContext(ctx, stmt.Query, stmt.Args...)
+		} else {
+			res, err = d.db.ExecContext(ctx, stmt.Query, stmt.Args...)
+		}
+		stmt.RowsAffected = rowsAffected(res, err)
+		return err
+	})
+	if err == nil {
+		d.noteWrite()
 	}
-	err = respErrorHandler(err)
 	return res, err
 }
 
@@ -184,19 +341,49 @@ func (d *Instance) BeginTx(opts *sql.TxOptions) (err error) {
 	if d.tx != nil {
 		return ErrTransactionAlreadyStarted
 	}
-	d.tx, err = d.db.BeginTxx(d.ctx, opts)
-	err = respErrorHandler(err)
+	if d.testTx != nil {
+		return d.beginTestTx()
+	}
+	started := time.Now()
+	err = d.run(&Statement{Op: OpBeginTx}, func(ctx context.Context, stmt *Statement) (err error) {
+		// The transaction is bound to the scene rather than ctx, which only lives as long as this call
+		d.tx, err = d.db.BeginTxx(d.ctx, opts)
+		return err
+	})
+	if err != nil && d.tx != nil {
+		// An interceptor failed the call after the transaction started
+		_ = d.tx.Rollback()
+		d.tx = nil
+	}
+	d.inFlight.inTx.Store(d.tx != nil)
+	d.inFlight.tx.Store(d.tx)
+	d.inFlight.forceClosed.Store(false)
+	d.txStarted = started
 	return
 }
 
 // Rollback will rill back any active transaction
 // If no transaction is active, it will return ErrNoActiveTransaction which can be safely ignored
 func (d *Instance) Rollback() error {
+	return d.rollback(nil)
+}
+
+// rollback rolls back the active transaction and hands cause to any AfterRollback callbacks
+func (d *Instance) rollback(cause error) error {
 	if d.tx == nil {
 		return ErrNoActiveTransaction
 	}
-	err := d.tx.Rollback()
+	var err error
+	if !d.inFlight.forceClosed.Swap(false) {
+		err = d.endTx(OpRollback)
+	}
+	d.observeTx(TxRolledBack)
 	d.tx = nil
+	d.inFlight.inTx.Store(false)
+	d.inFlight.tx.Store(nil)
+	d.savepoints = 0
+	d.txWrote = false
+	d.fireRollback(cause)
 	return err
 }
 
@@ -212,11 +399,10 @@ func (d *Instance) Close() []error {
 		}
 	}
 	if d.tx != nil {
-		err = d.tx.Rollback()
+		err = d.rollback(ErrClosedWithActiveTransaction)
 		if err != nil {
 			errors = append(errors, err)
 		}
-		d.tx = nil
 	}
 	for _, v := range d.children {
 		errs := v.Close()
@@ -233,9 +419,20 @@ func (d *Instance) Commit() error {
 	if d.tx == nil {
 		return ErrNoActiveTransaction
 	}
-	err := d.tx.Commit()
+	if d.inFlight.forceClosed.Load() {
+		// The provider already rolled the transaction back while shutting down
+		_ = d.rollback(ErrShuttingDown)
+		return ErrShuttingDown
+	}
+	err := d.endTx(OpCommit)
 	if err == nil {
 		d.tx = nil
+		d.inFlight.inTx.Store(false)
+		d.inFlight.tx.Store(nil)
+		d.savepoints = 0
+		d.observeTx(TxCommitted)
+		d.noteCommit()
+		d.fireCommit()
 	}
 	return err
 }
@@ -247,11 +444,30 @@ func (d *Instance) InTx() bool {
 
 // PartialCommit performs a commit and then immediately opens a new transaction.
 // NOTE: THIS DOES HOLD LOCKS FROM THE PREVIOUS TRANSACTION
+// Committing releases every savepoint, so this will return ErrNestedTransactionActive inside a nested RequireTx.
 func (d *Instance) PartialCommit() error {
 	if d.tx == nil {
 		return ErrNoActiveTransaction
 	}
-	_, err := d.Exec("COMMIT AND CHAIN NO RELEASE;")
+	if d.savepoints > 0 {
+		return ErrNestedTransactionActive
+	}
+	statement := "COMMIT AND CHAIN NO RELEASE;"
+	if d.testTx != nil {
+		// Setting a savepoint again moves it, which is as close to a commit as a test transaction gets
+		statement = "SAVEPOINT " + d.testSavepoint
+		if err := d.testTx.newest(d.testSavepoint); err != nil {
+			return err
+		}
+	}
+	err := d.txControl(OpPartialCommit, statement)
+	if err == nil {
+		// The chained transaction is measured on its own
+		d.observeTx(TxCommitted)
+		d.txStarted = time.Now()
+		d.noteCommit()
+		d.fireCommit()
+	}
 	return err
 }
 
@@ -262,16 +478,24 @@ func (d *Instance) Ping() error {
 }
 
 // RequireTx is used to have a critical section that requires beind under a transaction, if its already managed
-// it will fall into the existing transaction. If there isn't a transaction active, it will autocommit if there is no error
+// it will fall into the existing transaction under a savepoint, so an error only rolls back the work done by f.
+// If there isn't a transaction active, it will autocommit if there is no error
 func (d *Instance) RequireTx(f func(db *Instance) error) (err error) {
+	if err = d.beginUnitOfWork(); err != nil {
+		return err
+	}
 	if d.InTx() {
-		return f(d)
+		return d.nestedTx(f)
 	}
 	err = d.BeginTx(nil)
 	if err != nil {
 		return stack.Trace(err)
 	}
-	defer d.Rollback()
+	defer func() {
+		if d.InTx() {
+			_ = d.rollback(err)
+		}
+	}()
 	err = f(d)
 	if err == nil {
 		return d.Commit()
@@ -279,21 +503,97 @@ func (d *Instance) RequireTx(f func(db *Instance) error) (err error) {
 	return err
 }
 
+// nestedTx runs f under a savepoint of the active transaction.
+// The savepoint is released on success and rolled back to if f returns an error.
+// A deadlock has already rolled back the whole transaction in MySQL, so the transaction is ended instead.
+func (d *Instance) nestedTx(f func(db *Instance) error) (err error) {
+	d.savepoints++
+	name := "scene_sp_" + strconv.Itoa(d.savepoints)
+	if d.testTx != nil {
+		// Other instances share the test transaction's connection
+		name = d.testSavepoint + "_sp_" + strconv.Itoa(d.savepoints)
+	}
+	if err = d.txControl(OpSavepoint, "SAVEPOINT "+name); err != nil {
+		d.savepoints--
+		return stack.Trace(err)
+	}
+	d.trackSavepoint(name)
+	mark := d.hookMark()
+	err = f(d)
+	if !d.InTx() {
+		// f ended the transaction itself, there is nothing left to release
+		return err
+	}
+	d.savepoints--
+	if err != nil {
+		rbErr := d.endSavepoint(name, true)
+		if IsDeadlocked(err) {
+			// MySQL already rolled back the whole transaction and its savepoints with it, the transaction is dead
+			if txErr := d.rollback(err); rbErr == nil {
+				rbErr = txErr
+			}
+			if rbErr != nil {
+				return errors.WithMessagef(err, "rolling back transaction after deadlock in savepoint %v failed: %v", name, rbErr)
+			}
+			return err
+		}
+		if rbErr == nil {
+			rbErr = d.txControl(OpSavepoint, "ROLLBACK TO SAVEPOINT "+name)
+		}
+		if rbErr != nil {
+			return errors.WithMessagef(err, "rolling back savepoint %v failed: %v", name, rbErr)
+		}
+		d.rewindHooks(mark, err)
+		return err
+	}
+	if err = d.endSavepoint(name, false); err != nil {
+		return err
+	}
+	return d.txControl(OpSavepoint, "RELEASE SAVEPOINT "+name)
+}
+
+// txControl runs a transaction control statement (savepoints, chained commits) on the active transaction
+func (d *Instance) txControl(op Op, statement string) error {
+	if err := d.unclosedCheck(); err != nil {
+		return err
+	}
+	return d.run(&Statement{Op: op, Query: statement}, func(ctx context.Context, stmt *Statement) error {
+		_, err := d.tx.ExecContext(ctx, stmt.Query)
+		return err
+	})
+}
+
+// rowsAffected gets the rows affected by a successful exec, -1 if it is unknown
+func rowsAffected(res sql.Result, err error) int64 {
+	if err != nil || res == nil {
+		return -1
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return affected
+}
+
 func (d *Instance) unclosedCheck() error {
 	if d.currentOpenRows != nil && !d.currentOpenRows.IsClosed() {
+		d.observeUnclosedRows()
 		return errors.Wrapf(ErrRowsNotClosed, "opened on %v", d.lastOpenedLocation)
 	}
 	return nil
 }
 
-func (d *Instance) rowKeeper(rows rowCloser) error {
+// rowKeeper tracks the currently open rows, skip is the number of frames between rowKeeper and the caller the rows
+// are attributed to
+func (d *Instance) rowKeeper(rows rowCloser, skip int) error {
 	if d.currentOpenRows != nil {
 		if err := d.currentOpenRows.Close(); err != nil {
 			return errors.Wrapf(err, "opened on %v", d.lastOpenedLocation)
 		}
 	}
-	_, file, line, _ := runtime.Caller(2)
+	_, file, line, _ := runtime.Caller(skip + 1)
 	d.lastOpenedLocation = file + ":" + strconv.Itoa(line)
 	d.currentOpenRows = rows
+	d.inFlight.rows.Store(&trackedRows{rows: rows, location: d.lastOpenedLocation})
 	return nil
 }
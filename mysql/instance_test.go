@@ -230,3 +230,31 @@ func TestInstance_CloseDetection(t *testing.T) {
 	rows.Close()
 
 }
+
+func TestInstance_RequireTxNested(t *testing.T) {
+	instance := setupInstance(t)
+	statement := "SELECT count(*) FROM test_kvp"
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		_, err := db.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('outer','outer')")
+		require.NoError(t, err)
+		innerErr := db.RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('inner','inner')")
+			require.NoError(t, err)
+			return errors.New("inner failure")
+		})
+		require.EqualError(t, innerErr, "inner failure")
+		require.True(t, db.InTx())
+		require.ErrorIs(t, db.RequireTx(func(db *mysql.Instance) error {
+			return db.PartialCommit()
+		}), mysql.ErrNestedTransactionActive)
+		return db.RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('inner2','inner2')")
+			return err
+		})
+	}))
+	var ct int
+	require.NoError(t, instance.QueryRow(statement).Scan(&ct))
+	require.Equal(t, 2, ct)
+	require.NoError(t, instance.QueryRow("SELECT count(*) FROM test_kvp WHERE `key` = 'inner'").Scan(&ct))
+	require.Equal(t, 0, ct)
+}
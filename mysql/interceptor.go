@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"time"
+)
+
+// Op identifies the Instance call a statement is passing through
+type Op string
+
+const (
+	OpQuery         Op = "Query"
+	OpQueryx        Op = "Queryx"
+	OpQueryRow      Op = "QueryRow"
+	OpQueryRowx     Op = "QueryRowx"
+	OpExec          Op = "Exec"
+	OpBeginTx       Op = "BeginTx"
+	OpCommit        Op = "Commit"
+	OpRollback      Op = "Rollback"
+	OpPartialCommit Op = "PartialCommit"
+	// OpSavepoint covers the savepoint statements issued by a nested RequireTx
+	OpSavepoint Op = "Savepoint"
+)
+
+// Statement is an operation passing through the interceptor chain.
+// Interceptors may rewrite Query and Args before calling next, BeginTx, Commit and Rollback have no Query.
+type Statement struct {
+	Op    Op
+	Query string
+	Args  []any
+	// InTx is set if a transaction was active when the operation started, this includes the test transaction of
+	// WithTestTx
+	InTx bool
+	// RowsAffected is set by Exec once it has run, it is -1 otherwise
+	RowsAffected int64
+}
+
+// Invoker runs the rest of the chain for a statement
+type Invoker func(ctx context.Context, stmt *Statement) error
+
+// Interceptor observes or modifies an operation, it must call next to run it (unless it is failing the operation).
+// The context passed to next is the one the statement runs under, BeginTx is the exception since the transaction
+// is bound to the scene. Errors from MySQL reach interceptors as *mysql.MySQLError.
+type Interceptor func(ctx context.Context, stmt *Statement, next Invoker) error
+
+// WithInterceptors runs every operation of the provider's instances through the interceptors, the first interceptor
+// is the outermost. Interceptors registered through WithInterceptors run inside the tracing and metrics interceptors.
+func WithInterceptors(interceptors ...Interceptor) ProviderOption {
+	return func(provider *Provider) {
+		provider.interceptors = append(provider.interceptors, interceptors...)
+	}
+}
+
+// Use adds interceptors to this instance, they run inside the ones already present (such as the provider's)
+func (d *Instance) Use(interceptors ...Interceptor) {
+	// Instances derived from each other share a backing array, so the chain is always copied
+	d.interceptors = append(d.interceptors[:len(d.interceptors):len(d.interceptors)], interceptors...)
+}
+
+// run passes stmt through the interceptors and then into exec
+func (d *Instance) run(stmt *Statement, exec Invoker) error {
+	if d.unavailable != nil {
+		return d.unavailable
+	}
+	stmt.InTx = d.activeTx() != nil
+	stmt.RowsAffected = -1
+	invoke := func(ctx context.Context, stmt *Statement) error {
+		if tagged := d.comment(ctx, stmt.Query); tagged != stmt.Query {
+			// Interceptors only see the statement as it was written
+			commented := *stmt
+			commented.Query = tagged
+			err := exec(ctx, &commented)
+			stmt.RowsAffected = commented.RowsAffected
+			return respErrorHandler(err)
+		}
+		return respErrorHandler(exec(ctx, stmt))
+	}
+	for idx := len(d.interceptors) - 1; idx >= 0; idx-- {
+		interceptor, next := d.interceptors[idx], invoke
+		invoke = func(ctx context.Context, stmt *Statement) error {
+			return interceptor(ctx, stmt, next)
+		}
+	}
+	started := time.Now()
+	err := invoke(d.ctx, stmt)
+	d.stats.record(stmt, time.Since(started))
+	return d.queryError(stmt, err)
+}
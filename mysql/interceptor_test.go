@@ -0,0 +1,117 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestInstance_Interceptors(t *testing.T) {
+	var seen []string
+	recorder := func(name string) mysql.Interceptor {
+		return func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			seen = append(seen, name+":"+string(stmt.Op))
+			return next(ctx, stmt)
+		}
+	}
+	instance := internal.NewInstance(t, nil,
+		mysql.WithDB(internal.OpenFakeDB(t, "intercepted")),
+		mysql.WithInterceptors(recorder("outer"), recorder("inner")),
+	)
+
+	t.Run("order", func(t *testing.T) {
+		seen = nil
+		child := instance.SpawnChild()
+		child.Use(recorder("instance"))
+		require.NoError(t, child.QueryRow("SELECT source").Err())
+		require.Equal(t, []string{"outer:QueryRow", "inner:QueryRow", "instance:QueryRow"}, seen)
+		seen = nil
+		require.NoError(t, instance.QueryRow("SELECT source").Err())
+		require.Equal(t, []string{"outer:QueryRow", "inner:QueryRow"}, seen, "instance interceptors are not shared")
+	})
+	t.Run("transactions", func(t *testing.T) {
+		seen = nil
+		require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+			return db.RequireTx(func(db *mysql.Instance) error {
+				_, err := db.Exec("DELETE FROM test_kvp")
+				return err
+			})
+		}))
+		require.Equal(t, []string{
+			"outer:BeginTx", "inner:BeginTx",
+			"outer:Savepoint", "inner:Savepoint",
+			"outer:Exec", "inner:Exec",
+			"outer:Savepoint", "inner:Savepoint",
+			"outer:Commit", "inner:Commit",
+		}, seen)
+	})
+	t.Run("rewrite", func(t *testing.T) {
+		child := instance.SpawnChild()
+		child.Use(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			stmt.Query = "/* rewritten */ " + stmt.Query
+			err := next(ctx, stmt)
+			require.Equal(t, int64(1), stmt.RowsAffected)
+			require.False(t, stmt.InTx)
+			return err
+		})
+		_, err := child.Exec("UPDATE test_kvp SET val = ?", "test")
+		require.NoError(t, err)
+		require.Contains(t, internal.FakeDBStatements("intercepted"), "/* rewritten */ UPDATE test_kvp SET val = ?")
+	})
+	t.Run("fail operations", func(t *testing.T) {
+		errReadOnly := errors.New("read only")
+		child := instance.SpawnChild()
+		child.Use(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			if stmt.Op == mysql.OpExec {
+				return errReadOnly
+			}
+			return next(ctx, stmt)
+		})
+		before := len(internal.FakeDBStatements("intercepted"))
+		_, err := child.Exec("DELETE FROM test_kvp")
+		require.ErrorIs(t, err, errReadOnly)
+		require.Len(t, internal.FakeDBStatements("intercepted"), before)
+
+		inUse := instance.Raw().Stats().InUse
+		child = instance.SpawnChild()
+		child.Use(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			_ = next(ctx, stmt)
+			return errReadOnly
+		})
+		require.ErrorIs(t, child.QueryRow("SELECT source").Scan(new(string)), errReadOnly)
+		require.ErrorIs(t, child.QueryRowx("SELECT source").Scan(new(string)), errReadOnly)
+		_, err = child.Query("SELECT source")
+		require.ErrorIs(t, err, errReadOnly)
+		require.ErrorIs(t, child.BeginTx(nil), errReadOnly)
+		require.False(t, child.InTx())
+		// Nothing was left open by the failed calls, including the driver connections
+		require.Equal(t, inUse, instance.Raw().Stats().InUse)
+		require.NoError(t, instance.QueryRow("SELECT source").Scan(new(string)))
+	})
+	t.Run("fail rollback", func(t *testing.T) {
+		errNoRollback := errors.New("no rollback")
+		child := instance.SpawnChild()
+		child.Use(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			if stmt.Op == mysql.OpRollback {
+				return errNoRollback
+			}
+			return next(ctx, stmt)
+		})
+		var rolledBack bool
+		inUse := instance.Raw().Stats().InUse
+		require.NoError(t, child.BeginTx(nil))
+		child.AfterRollback(func(err error) {
+			rolledBack = true
+		})
+		require.ErrorIs(t, child.Rollback(), errNoRollback)
+		require.False(t, child.InTx())
+		require.True(t, rolledBack)
+		statements := internal.FakeDBStatements("intercepted")
+		require.Equal(t, "ROLLBACK", statements[len(statements)-1], "the transaction is rolled back regardless")
+		require.Equal(t, inUse, instance.Raw().Stats().InUse, "the connection went back to the pool")
+	})
+}
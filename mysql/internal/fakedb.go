@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const fakeDriverName = "scene-db-fake"
+
+var fakeDriver = &FakeDriver{
+	down:       make(map[string]bool),
+	statements: make(map[string][]string),
+	responses:  make(map[string]map[string]driver.Value),
+	failures:   make(map[string]map[string]error),
+}
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver)
+}
+
+// ErrFakeDBDown is returned by any fake connection that was marked down
+var ErrFakeDBDown = errors.New("fake database is down")
+
+// FakeDriver is a minimal database/sql driver that allows for testing routing and instrumentation without a server.
+// Every query returns a single row with a single "source" column containing the name of the database it ran on
+// (unless a response was set for it), every statement is recorded against that name.
+type FakeDriver struct {
+	mu         sync.Mutex
+	down       map[string]bool
+	statements map[string][]string
+	responses  map[string]map[string]driver.Value
+	failures   map[string]map[string]error
+}
+
+// OpenFakeDB opens a fake database, the name identifies it in query results and recorded statements
+func OpenFakeDB(tb testing.TB, name string) *sqlx.DB {
+	tb.Helper()
+	db, err := sqlx.Open(fakeDriverName, name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = db.Close()
+		fakeDriver.mu.Lock()
+		delete(fakeDriver.down, name)
+		delete(fakeDriver.statements, name)
+		delete(fakeDriver.responses, name)
+		delete(fakeDriver.failures, name)
+		fakeDriver.mu.Unlock()
+	})
+	return db
+}
+
+// SetFakeDBDown marks a fake database as down (or back up), every operation against it will fail while it is down
+func SetFakeDBDown(name string, down bool) {
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	fakeDriver.down[name] = down
+}
+
+// SetFakeDBResponse sets the single value a fake database returns for an exact query
+func SetFakeDBResponse(name, query string, value driver.Value) {
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	if fakeDriver.responses[name] == nil {
+		fakeDriver.responses[name] = make(map[string]driver.Value)
+	}
+	fakeDriver.responses[name][query] = value
+}
+
+// SetFakeDBError makes an exact query (or exec) against a fake database fail with err, a nil err clears it
+func SetFakeDBError(name, query string, err error) {
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	if fakeDriver.failures[name] == nil {
+		fakeDriver.failures[name] = make(map[string]error)
+	}
+	if err == nil {
+		delete(fakeDriver.failures[name], query)
+		return
+	}
+	fakeDriver.failures[name][query] = err
+}
+
+// FakeDBStatements gets every statement that ran against a fake database
+func FakeDBStatements(name string) []string {
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	return append([]string(nil), fakeDriver.statements[name]...)
+}
+
+func (f *FakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{name: name}, nil
+}
+
+func (f *FakeDriver) record(name, statement string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down[name] {
+		return ErrFakeDBDown
+	}
+	f.statements[name] = append(f.statements[name], statement)
+	return f.failures[name][statement]
+}
+
+type fakeConn struct {
+	name string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, fakeDriver.record(c.name, "BEGIN")
+}
+
+func (c *fakeConn) Commit() error {
+	return fakeDriver.record(c.name, "COMMIT")
+}
+
+func (c *fakeConn) Rollback() error {
+	return fakeDriver.record(c.name, "ROLLBACK")
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	if fakeDriver.down[c.name] {
+		return ErrFakeDBDown
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := fakeDriver.record(c.name, query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := fakeDriver.record(c.name, query); err != nil {
+		return nil, err
+	}
+	fakeDriver.mu.Lock()
+	defer fakeDriver.mu.Unlock()
+	if value, found := fakeDriver.responses[c.name][query]; found {
+		return &fakeRows{value: value}, nil
+	}
+	return &fakeRows{value: c.name}, nil
+}
+
+type fakeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"source"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
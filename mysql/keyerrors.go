@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DuplicateKeyError is a parsed duplicate key (1062) error, it unwraps to the *mysql.MySQLError it was parsed from
+type DuplicateKeyError struct {
+	// Table is the table the key belongs to, MySQL only reports it from 8.0.19 on so it may be empty
+	Table string
+	// KeyName is the name of the unique index that collided, PRIMARY for the primary key
+	KeyName string
+	// Value is the entry that collided, the columns of a composite key are joined by a dash
+	Value string
+	Err   *mysql.MySQLError
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// ForeignKeyError is a parsed foreign key (1451, 1452) error, it unwraps to the *mysql.MySQLError it was parsed from
+type ForeignKeyError struct {
+	Constraint string
+	// Schema and Table are where the referencing (child) row lives
+	Schema  string
+	Table   string
+	Columns []string
+	// ReferencedSchema and ReferencedTable are where the referenced (parent) row lives
+	ReferencedSchema  string
+	ReferencedTable   string
+	ReferencedColumns []string
+	Err               *mysql.MySQLError
+}
+
+func (e *ForeignKeyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ForeignKeyError) Unwrap() error {
+	return e.Err
+}
+
+// IsParentRow checks if the error came from deleting or updating a row that is still referenced (1451), rather
+// than from writing a row that references a missing row (1452)
+func (e *ForeignKeyError) IsParentRow() bool {
+	return e.Err.Number == ErrCodeRowIsReferenced || e.Err.Number == ErrCodeRowIsReferencedOld
+}
+
+var foreignKeyExp = regexp.MustCompile("\\((.+?), CONSTRAINT (`(?:[^`]|``)+`) FOREIGN KEY \\((.+?)\\) REFERENCES (.+?) \\((.+?)\\)")
+
+// ParseDuplicateKeyError gets the details of a duplicate key error, err may be wrapped.
+// false is returned if err is not a duplicate key error or the message could not be parsed.
+// Both the MySQL 5.7 (for key 'name') and 8.0 (for key 'table.name') formats are supported.
+func ParseDuplicateKeyError(err error) (*DuplicateKeyError, bool) {
+	mysqlErr := getMySQLError(err)
+	if mysqlErr == nil || mysqlErr.Number != ErrCodeDuplicateKey {
+		return nil, false
+	}
+	const prefix, separator = "Duplicate entry '", "' for key '"
+	msg := mysqlErr.Message
+	split := strings.LastIndex(msg, separator)
+	if !strings.HasPrefix(msg, prefix) || split < len(prefix) || !strings.HasSuffix(msg, "'") {
+		return nil, false
+	}
+	out := &DuplicateKeyError{
+		Value:   msg[len(prefix):split],
+		KeyName: msg[split+len(separator) : len(msg)-1],
+		Err:     mysqlErr,
+	}
+	// Index names can not contain a dot unless quoted, which MySQL does not do here
+	if dot := strings.LastIndex(out.KeyName, "."); dot >= 0 {
+		out.Table, out.KeyName = out.KeyName[:dot], out.KeyName[dot+1:]
+	}
+	return out, true
+}
+
+// ParseForeignKeyError gets the details of a foreign key error, err may be wrapped.
+// false is returned if err is not a foreign key error or the message could not be parsed.
+func ParseForeignKeyError(err error) (*ForeignKeyError, bool) {
+	mysqlErr := getMySQLError(err)
+	if mysqlErr == nil || ClassifyError(mysqlErr) != ErrorKindForeignKey {
+		return nil, false
+	}
+	match := foreignKeyExp.FindStringSubmatch(mysqlErr.Message)
+	if match == nil {
+		return nil, false
+	}
+	out := &ForeignKeyError{
+		Constraint:        unquoteIdentifier(match[2]),
+		Columns:           splitIdentifiers(match[3], ","),
+		ReferencedColumns: splitIdentifiers(match[5], ","),
+		Err:               mysqlErr,
+	}
+	out.Schema, out.Table = qualifiedName(match[1])
+	out.ReferencedSchema, out.ReferencedTable = qualifiedName(match[4])
+	if len(out.ReferencedSchema) == 0 {
+		// Tables in the same schema are not qualified
+		out.ReferencedSchema = out.Schema
+	}
+	return out, true
+}
+
+// qualifiedName splits `schema`.`table` (or just `table`) into its parts
+func qualifiedName(name string) (schema string, table string) {
+	parts := splitIdentifiers(name, ".")
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], "."), parts[len(parts)-1]
+}
+
+// splitIdentifiers splits a list of backtick quoted identifiers on sep, separators inside quotes are ignored
+func splitIdentifiers(list string, sep string) []string {
+	var out []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(list); i++ {
+		switch {
+		case list[i] == '`':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(list[i:], sep):
+			out = append(out, unquoteIdentifier(list[start:i]))
+			start = i + len(sep)
+		}
+	}
+	return append(out, unquoteIdentifier(list[start:]))
+}
+
+func unquoteIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) >= 2 && identifier[0] == '`' && identifier[len(identifier)-1] == '`' {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], "``", "`")
+	}
+	return identifier
+}
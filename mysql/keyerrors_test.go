@@ -0,0 +1,101 @@
+package mysql_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	md "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+func TestParseDuplicateKeyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    mysql.DuplicateKeyError
+	}{
+		{
+			name:    "MySQL 5.7",
+			message: "Duplicate entry 'bob@example.com' for key 'uniq_email'",
+			want:    mysql.DuplicateKeyError{KeyName: "uniq_email", Value: "bob@example.com"},
+		},
+		{
+			name:    "MySQL 8.0",
+			message: "Duplicate entry 'bob@example.com' for key 'users.uniq_email'",
+			want:    mysql.DuplicateKeyError{Table: "users", KeyName: "uniq_email", Value: "bob@example.com"},
+		},
+		{
+			name:    "Primary key",
+			message: "Duplicate entry '12' for key 'test_kvp.PRIMARY'",
+			want:    mysql.DuplicateKeyError{Table: "test_kvp", KeyName: "PRIMARY", Value: "12"},
+		},
+		{
+			name:    "Composite key with quotes",
+			message: "Duplicate entry 'o'brien-' for key' for key 'people.uniq_name'",
+			want:    mysql.DuplicateKeyError{Table: "people", KeyName: "uniq_name", Value: "o'brien-' for key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysqlErr := &md.MySQLError{Number: mysql.ErrCodeDuplicateKey, Message: tt.message}
+			got, ok := mysql.ParseDuplicateKeyError(fmt.Errorf("inserting: %w", mysqlErr))
+			require.True(t, ok)
+			tt.want.Err = mysqlErr
+			require.Equal(t, &tt.want, got)
+			require.True(t, mysql.IsDuplicateKeyError(got))
+			require.Equal(t, mysql.ErrCodeDuplicateKey, mysql.GetErrorCode(got))
+		})
+	}
+	_, ok := mysql.ParseDuplicateKeyError(errors.New("test"))
+	require.False(t, ok)
+	_, ok = mysql.ParseDuplicateKeyError(&md.MySQLError{Number: mysql.ErrCodeDuplicateKey, Message: "unexpected"})
+	require.False(t, ok)
+}
+
+func TestParseForeignKeyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    uint16
+		message string
+		want    mysql.ForeignKeyError
+		parent  bool
+	}{
+		{
+			name: "Referenced row",
+			code: mysql.ErrCodeRowIsReferenced,
+			message: "Cannot delete or update a parent row: a foreign key constraint fails " +
+				"(`shop`.`orders`, CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))",
+			want: mysql.ForeignKeyError{
+				Constraint: "fk_orders_user", Schema: "shop", Table: "orders", Columns: []string{"user_id"},
+				ReferencedSchema: "shop", ReferencedTable: "users", ReferencedColumns: []string{"id"},
+			},
+			parent: true,
+		},
+		{
+			name: "Missing row",
+			code: mysql.ErrCodeNoReferencedRow,
+			message: "Cannot add or update a child row: a foreign key constraint fails " +
+				"(`shop`.`order items`, CONSTRAINT `fk_items``order` FOREIGN KEY (`order_id`, `shop_id`) " +
+				"REFERENCES `archive`.`orders` (`id`, `shop_id`) ON DELETE CASCADE)",
+			want: mysql.ForeignKeyError{
+				Constraint: "fk_items`order", Schema: "shop", Table: "order items", Columns: []string{"order_id", "shop_id"},
+				ReferencedSchema: "archive", ReferencedTable: "orders", ReferencedColumns: []string{"id", "shop_id"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysqlErr := &md.MySQLError{Number: tt.code, Message: tt.message}
+			got, ok := mysql.ParseForeignKeyError(fmt.Errorf("saving: %w", mysqlErr))
+			require.True(t, ok)
+			tt.want.Err = mysqlErr
+			require.Equal(t, &tt.want, got)
+			require.Equal(t, tt.parent, got.IsParentRow())
+			require.True(t, mysql.IsForeignKeyViolation(got))
+		})
+	}
+	_, ok := mysql.ParseForeignKeyError(&md.MySQLError{Number: mysql.ErrCodeDuplicateKey, Message: tests[0].message})
+	require.False(t, ok)
+}
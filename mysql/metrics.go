@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// TxOutcome is how a transaction ended
+type TxOutcome string
+
+const (
+	TxCommitted  TxOutcome = "commit"
+	TxRolledBack TxOutcome = "rollback"
+)
+
+// PrimaryPool is the pool name the primary's stats are reported under, replicas are reported as replica_<index>
+const PrimaryPool = "primary"
+
+// MetricsCollector receives measurements from a provider and its instances, see WithMetrics.
+// provider is the name the provider was registered with (empty for the default provider).
+// Implementations must be safe for concurrent use, see the prommetrics package for a Prometheus adapter.
+type MetricsCollector interface {
+	// RegisterPools is called once the provider is connected, stats gets the current stats of each pool by name
+	RegisterPools(provider string, stats func() map[string]sql.DBStats)
+	// ObserveOperation records how long a statement or transaction call took, err is nil if it succeeded
+	ObserveOperation(provider string, op string, duration time.Duration, err error)
+	// ObserveTransaction records how long a transaction was open for and how it ended
+	ObserveTransaction(provider string, duration time.Duration, outcome TxOutcome)
+	// ObserveUnclosedRows records a statement that was refused because rows were left open
+	ObserveUnclosedRows(provider string)
+}
+
+// WithMetrics reports pool stats, operation latencies, transaction outcomes, unclosed rows and errors to collector
+func WithMetrics(collector MetricsCollector) ProviderOption {
+	return func(provider *Provider) {
+		provider.metrics = collector
+	}
+}
+
+// poolStats gets the stats of the primary and every replica
+func (i Provider) poolStats() map[string]sql.DBStats {
+	out := map[string]sql.DBStats{PrimaryPool: i.DB.Stats()}
+	if i.Replicas != nil {
+		for idx, stats := range i.Replicas.Stats() {
+			out["replica_"+strconv.Itoa(idx)] = stats
+		}
+	}
+	return out
+}
+
+// metricsInterceptor measures every operation
+func metricsInterceptor(collector MetricsCollector, provider string) Interceptor {
+	return func(ctx context.Context, stmt *Statement, next Invoker) error {
+		started := time.Now()
+		err := next(ctx, stmt)
+		collector.ObserveOperation(provider, string(stmt.Op), time.Since(started), err)
+		return err
+	}
+}
+
+func (d *Instance) observeTx(outcome TxOutcome) {
+	if d.metrics != nil && !d.txStarted.IsZero() {
+		d.metrics.ObserveTransaction(d.provider, time.Since(d.txStarted), outcome)
+	}
+}
+
+func (d *Instance) observeUnclosedRows() {
+	if d.metrics != nil {
+		d.metrics.ObserveUnclosedRows(d.provider)
+	}
+}
@@ -0,0 +1,171 @@
+// Package migrations evolves a schema with versioned up and down scripts.
+//
+// Migrations are read from the root of an fs.FS, every migration has an up script and optionally a down script:
+//
+//	0001_create_users.up.sql
+//	0001_create_users.down.sql
+//	0002_add_email.up.sql
+//
+// Applied versions are recorded in a schema_migrations table along with a checksum of their up script, so a
+// migration that was edited after it was applied is caught rather than silently diverging.
+//
+// MySQL commits DDL implicitly, so a migration that fails part way can not be rolled back. It is recorded as dirty
+// before it runs and migrating refuses to continue until the schema was fixed by hand and the migration was resolved
+// with Migrator.Force.
+//
+//	migrator, err := migrations.New(instance, os.DirFS("db/migrations"))
+//	applied, err := migrator.Up()
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var fileNameExp = regexp.MustCompile(`^(\d+)_([^.]+)\.(up|down)\.sql$`)
+
+var ErrNoDownScript = errors.New("migration has no down script")
+var ErrUnknownVersion = errors.New("applied migration is not in the migration files")
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+var ErrDirty = errors.New("migration failed part way, fix the schema and resolve it with Force")
+
+// Direction is the way a migration is run
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// Migration is a versioned change to the schema
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	// Down is empty if there is no down script (or it is empty), the migration can not be rolled back
+	Down string
+	// Checksum is the hex encoded SHA-256 of the up script
+	Checksum string
+}
+
+// Step is a migration run (or planned to run, see WithDryRun) in a direction
+type Step struct {
+	Migration
+	Direction Direction
+}
+
+// Status describes a migration and whether it was applied
+type Status struct {
+	Migration
+	// Applied is set when the migration was applied, a dirty migration is neither applied nor pending
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the up script changed since it was applied
+	Modified bool
+	// Unknown is set when the version was applied but there is no longer a file for it, only Version and Name are set
+	Unknown bool
+	// Dirty is set when the migration failed part way, see Migrator.Force
+	Dirty bool
+}
+
+// MigrationError is returned when a migration could not be run
+type MigrationError struct {
+	Version   uint64
+	Name      string
+	Direction Direction
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migrating %v %v_%v: %v", e.Direction, e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumError is returned when an applied migration's up script was changed after it was applied
+type ChecksumError struct {
+	Version  uint64
+	Name     string
+	Applied  string
+	Checksum string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("migration %v_%v was modified after it was applied (checksum %v, applied with %v)", e.Version, e.Name, e.Checksum, e.Applied)
+}
+
+// Load reads the migrations in the root of fsys ordered by version. Files that are not .sql are ignored, loading fails
+// on a .sql file that is not named <version>_<name>.(up|down).sql, a duplicate version, version 0 or a down script
+// without an up script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "reading migrations")
+	}
+	byVersion := make(map[uint64]*Migration)
+	// Scripts are keyed by version and direction, a script may legitimately be empty
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileNameExp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, errors.Errorf("%v is not named <version>_<name>.up.sql or <version>_<name>.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing the version of %v", entry.Name())
+		}
+		if version == 0 {
+			// Migrating to version 0 rolls back everything
+			return nil, errors.Errorf("%v uses version 0, versions start at 1", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %v", entry.Name())
+		}
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, errors.Errorf("version %v is used by both %v and %v", version, migration.Name, match[2])
+		}
+		key := strconv.FormatUint(version, 10) + "." + match[3]
+		if seen[key] {
+			return nil, errors.Errorf("version %v has more than one %v script", version, match[3])
+		}
+		seen[key] = true
+		if Direction(match[3]) == Down {
+			migration.Down = string(data)
+		} else {
+			migration.Up = string(data)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !seen[strconv.FormatUint(migration.Version, 10)+"."+string(Up)] {
+			return nil, errors.Errorf("migration %v_%v has no up script", migration.Version, migration.Name)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		out = append(out, *migration)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Version < out[j].Version
+	})
+	return out, nil
+}
@@ -0,0 +1,219 @@
+package migrations_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	md "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+	"github.com/weisbartb/scene-db/mysql/migrations"
+	"github.com/weisbartb/scene-db/mysql/mysqltest"
+)
+
+var files = fstest.MapFS{
+	"0001_kvp.up.sql":       {Data: []byte("CREATE TABLE `test_kvp` (`key` VARCHAR(191) NOT NULL PRIMARY KEY, `val` VARCHAR(191) NOT NULL);")},
+	"0001_kvp.down.sql":     {Data: []byte("DROP TABLE `test_kvp`;")},
+	"0002_seed.up.sql":      {Data: []byte("INSERT INTO `test_kvp` (`key`, `val`) VALUES ('a', 'semi;colon');\n-- second row\nINSERT INTO `test_kvp` (`key`, `val`) VALUES ('b', '2');")},
+	"0002_seed.down.sql":    {Data: []byte("DELETE FROM `test_kvp`;")},
+	"0010_log.up.sql":       {Data: []byte("ALTER TABLE `test_kvp` ADD COLUMN `log` TEXT NULL;")},
+	"0010_log.down.sql":     {Data: []byte("ALTER TABLE `test_kvp` DROP COLUMN `log`;")},
+	"README.md":             {Data: []byte("ignored")},
+	"archive/0003_x.up.sql": {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := migrations.Load(files)
+	require.NoError(t, err)
+	require.Len(t, loaded, 3)
+	require.Equal(t, []uint64{1, 2, 10}, []uint64{loaded[0].Version, loaded[1].Version, loaded[2].Version})
+	require.Equal(t, "seed", loaded[1].Name)
+	require.Equal(t, "DROP TABLE `test_kvp`;", loaded[0].Down)
+	require.Len(t, loaded[0].Checksum, 64)
+	require.NotEqual(t, loaded[0].Checksum, loaded[1].Checksum)
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":  {"0001-kvp.sql": {}},
+		"version 0": {"0_kvp.up.sql": {}},
+		"no up":     {"0001_kvp.down.sql": {}},
+		"duplicate": {"0001_kvp.up.sql": {}, "1_kvp.up.sql": {}},
+		"renamed":   {"0001_kvp.up.sql": {}, "0001_other.down.sql": {}},
+	} {
+		_, err := migrations.Load(fsys)
+		require.Error(t, err, name)
+	}
+}
+
+func TestMigrator_QueryErrors(t *testing.T) {
+	provider, err := mysql.NewSceneProvider(mysql.MySQLConfig{}, nil,
+		mysql.WithDB(internal.OpenFakeDB(t, "migrations")),
+		mysql.WithQueryErrors(mysql.QueryErrorOptions{}),
+	)
+	require.NoError(t, err)
+	factory, err := scene.NewSceneFactory(scene.Config{}, provider)
+	require.NoError(t, err)
+	ctx, err := factory.NewCtx()
+	require.NoError(t, err)
+	defer ctx.Complete()
+	internal.SetFakeDBError("migrations", "SELECT `version`, `name`, `checksum`, `applied_at`, `dirty` FROM `schema_migrations`", &md.MySQLError{
+		Number: mysql.ErrCodeUnknownColumn, Message: "Unknown column 'checksum' in 'field list'",
+	})
+	migrator, err := migrations.New(mysql.GetManagedDatabaseInstance(ctx), files)
+	require.NoError(t, err)
+	_, err = migrator.Status()
+	var queryErr *mysql.QueryError
+	require.True(t, errors.As(err, &queryErr))
+	require.Contains(t, queryErr.Caller, "migrations_test.go:", "the migrator is not the caller")
+}
+
+func TestMigrator(t *testing.T) {
+	db := mysqltest.NewTestDB(t)
+	instance := db.Instance(t)
+	migrator, err := migrations.New(instance, files)
+	require.NoError(t, err)
+	versions := func(steps []migrations.Step) (out []uint64) {
+		for _, step := range steps {
+			out = append(out, step.Version)
+		}
+		return out
+	}
+	applied := func(t *testing.T) (out []uint64) {
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		for _, status := range statuses {
+			if status.Applied {
+				out = append(out, status.Version)
+			}
+		}
+		return out
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		dryRun, err := migrations.New(instance, files, migrations.WithDryRun(true))
+		require.NoError(t, err)
+		steps, err := dryRun.Up()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1, 2, 10}, versions(steps))
+		require.Empty(t, applied(t))
+		_, err = instance.Exec("SELECT 1 FROM `test_kvp`")
+		require.True(t, mysql.IsUnknownTable(err))
+	})
+	t.Run("up", func(t *testing.T) {
+		steps, err := migrator.Up()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1, 2, 10}, versions(steps))
+		require.Equal(t, []uint64{1, 2, 10}, applied(t))
+		val, err := mysql.Get[string](instance, "SELECT `val` FROM `test_kvp` WHERE `key` = 'a'")
+		require.NoError(t, err)
+		require.Equal(t, "semi;colon", val)
+		steps, err = migrator.Up()
+		require.NoError(t, err)
+		require.Empty(t, steps)
+	})
+	t.Run("down and to", func(t *testing.T) {
+		steps, err := migrator.Down()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{10}, versions(steps))
+		require.Equal(t, []uint64{1, 2}, applied(t))
+		steps, err = migrator.To(1)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{2}, versions(steps))
+		steps, err = migrator.To(10)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{2, 10}, versions(steps))
+		_, err = migrator.To(5)
+		require.Error(t, err)
+	})
+	t.Run("failures", func(t *testing.T) {
+		broken := fstest.MapFS{}
+		for name, file := range files {
+			broken[name] = file
+		}
+		broken["0011_broken.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE `test_kvp` ADD COLUMN `extra` INT NULL;\nSELECT `missing` FROM `test_kvp`;")}
+		brokenMigrator, err := migrations.New(instance, broken)
+		require.NoError(t, err)
+		steps, err := brokenMigrator.Up()
+		require.Empty(t, steps)
+		var migrationErr *migrations.MigrationError
+		require.True(t, errors.As(err, &migrationErr))
+		require.Equal(t, uint64(11), migrationErr.Version)
+		var scriptErr *mysql.ScriptError
+		require.True(t, errors.As(err, &scriptErr))
+		require.Equal(t, 2, scriptErr.Line)
+		require.True(t, mysql.IsUnknownColumn(err))
+		require.Equal(t, []uint64{1, 2, 10}, applied(t))
+
+		// The ALTER TABLE committed implicitly, so the migration is left dirty until it is resolved
+		_, err = instance.Exec("SELECT `extra` FROM `test_kvp`")
+		require.NoError(t, err)
+		statuses, err := brokenMigrator.Status()
+		require.NoError(t, err)
+		require.True(t, statuses[3].Dirty)
+		require.False(t, statuses[3].Applied)
+		_, err = brokenMigrator.Up()
+		require.ErrorIs(t, err, migrations.ErrDirty)
+		_, err = migrator.Down()
+		require.ErrorIs(t, err, migrations.ErrDirty, "migrators without the dirty migration stop as well")
+		_, err = instance.Exec("ALTER TABLE `test_kvp` DROP COLUMN `extra`")
+		require.NoError(t, err)
+		require.NoError(t, brokenMigrator.Force(11, false))
+		_, err = instance.Exec("SELECT `extra` FROM `test_kvp`")
+		require.True(t, mysql.IsUnknownColumn(err))
+		require.Equal(t, []uint64{1, 2, 10}, applied(t))
+		require.Error(t, migrator.Force(11, true), "only known migrations can be recorded as applied")
+
+		modified := fstest.MapFS{}
+		for name, file := range files {
+			modified[name] = file
+		}
+		modified["0002_seed.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		modifiedMigrator, err := migrations.New(instance, modified)
+		require.NoError(t, err)
+		_, err = modifiedMigrator.Up()
+		var checksumErr *migrations.ChecksumError
+		require.True(t, errors.As(err, &checksumErr))
+		statuses, err = modifiedMigrator.Status()
+		require.NoError(t, err)
+		require.True(t, statuses[1].Modified)
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		_, err := migrator.To(0)
+		require.NoError(t, err)
+		require.Empty(t, applied(t))
+		var wg sync.WaitGroup
+		ran := make([][]migrations.Step, 3)
+		errs := make([]error, 3)
+		for i := range ran {
+			i := i
+			concurrent, err := migrations.New(db.Instance(t), files)
+			require.NoError(t, err)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ran[i], errs[i] = concurrent.Up()
+			}()
+		}
+		wg.Wait()
+		var total int
+		for i := range ran {
+			require.NoError(t, errs[i])
+			total += len(ran[i])
+		}
+		require.Equal(t, 3, total, "every migration ran once")
+		require.Equal(t, []uint64{1, 2, 10}, applied(t))
+	})
+	t.Run("long table name", func(t *testing.T) {
+		// The lock name would be longer than GET_LOCK accepts
+		longMigrator, err := migrations.New(instance, files, migrations.WithTable(strings.Repeat("m", 64)))
+		require.NoError(t, err)
+		require.NoError(t, longMigrator.Force(1, true))
+		statuses, err := longMigrator.Status()
+		require.NoError(t, err)
+		require.True(t, statuses[0].Applied)
+	})
+}
@@ -0,0 +1,419 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io/fs"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+// DefaultTable is the table applied migrations are recorded in
+const DefaultTable = "schema_migrations"
+
+// DefaultLockTimeout is how long a migrator waits for another one to finish before giving up
+const DefaultLockTimeout = time.Minute
+
+// maxLockName is the longest name GET_LOCK accepts
+const maxLockName = 64
+
+type options struct {
+	table       string
+	lockTimeout time.Duration
+	dryRun      bool
+}
+
+// Option customizes a Migrator
+type Option func(opts *options)
+
+// WithTable records applied migrations in a table other than DefaultTable
+func WithTable(table string) Option {
+	return func(opts *options) {
+		opts.table = table
+	}
+}
+
+// WithLockTimeout sets how long to wait for the migration lock, it is rounded down to the second
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.lockTimeout = timeout
+	}
+}
+
+// WithDryRun plans the steps without running or recording them, the lock is not taken and the table is not created
+func WithDryRun(dryRun bool) Option {
+	return func(opts *options) {
+		opts.dryRun = dryRun
+	}
+}
+
+// Migrator runs migrations through an instance, so the provider's interceptors, logging and error helpers apply.
+// A named lock (GET_LOCK) is held while migrating so concurrent deploys wait on each other instead of racing.
+type Migrator struct {
+	db         *mysql.Instance
+	migrations []Migration
+	opts       options
+}
+
+// applied is a row of the migrations table
+type applied struct {
+	Version   uint64    `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+	Dirty     bool      `db:"dirty"`
+}
+
+// New creates a migrator for the migrations in the root of fsys, see Load
+func New(db *mysql.Instance, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	o := options{table: DefaultTable, lockTimeout: DefaultLockTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Migrator{db: db, migrations: migrations, opts: o}, nil
+}
+
+// Migrations gets every migration ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status describes every migration and whether it was applied, ordered by version.
+// Versions that were applied but no longer have files are included and marked as Unknown.
+func (m *Migrator) Status() ([]Status, error) {
+	rows, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if row, ok := rows[migration.Version]; ok {
+			status.Applied = !row.Dirty
+			status.Dirty = row.Dirty
+			status.AppliedAt = row.AppliedAt
+			status.Modified = row.Checksum != migration.Checksum
+			delete(rows, migration.Version)
+		}
+		out = append(out, status)
+	}
+	for _, row := range rows {
+		out = append(out, Status{
+			Migration: Migration{Version: row.Version, Name: row.Name, Checksum: row.Checksum},
+			Applied:   !row.Dirty,
+			AppliedAt: row.AppliedAt,
+			Unknown:   true,
+			Dirty:     row.Dirty,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Version < out[j].Version
+	})
+	return out, nil
+}
+
+// Up applies every pending migration in order, returning the steps that ran
+func (m *Migrator) Up() ([]Step, error) {
+	return m.migrate(func(rows map[uint64]applied) ([]Step, error) {
+		return m.planUp(rows, math.MaxUint64), nil
+	})
+}
+
+// Down rolls back the most recently applied migration (by version), returning the step that ran
+func (m *Migrator) Down() ([]Step, error) {
+	return m.migrate(func(rows map[uint64]applied) ([]Step, error) {
+		var latest uint64
+		for version := range rows {
+			if version > latest {
+				latest = version
+			}
+		}
+		if latest == 0 {
+			return nil, nil
+		}
+		return m.planDown(rows, latest-1)
+	})
+}
+
+// To migrates up or down so that exactly the migrations up to and including version are applied.
+// Version 0 rolls back every migration.
+func (m *Migrator) To(version uint64) ([]Step, error) {
+	if version != 0 {
+		if _, err := m.migration(version); err != nil {
+			return nil, err
+		}
+	}
+	return m.migrate(func(rows map[uint64]applied) ([]Step, error) {
+		steps, err := m.planDown(rows, version)
+		if err != nil {
+			return nil, err
+		}
+		return append(steps, m.planUp(rows, version)...), nil
+	})
+}
+
+// Force resolves a migration that failed part way (see ErrDirty) without running anything, once the schema was fixed
+// by hand. If applied is set the migration is recorded as applied, otherwise it is recorded as not applied.
+// It can also be used to record a migration that was applied (or rolled back) by other means.
+func (m *Migrator) Force(version uint64, applied bool) error {
+	var migration Migration
+	if applied {
+		var err error
+		if migration, err = m.migration(version); err != nil {
+			return err
+		}
+	}
+	return m.locked(func(owned bool) error {
+		if err := m.createTable(); err != nil {
+			return err
+		}
+		if _, err := m.db.Exec("DELETE FROM "+m.table()+" WHERE `version` = ?", version); err != nil {
+			return errors.Wrapf(err, "forcing version %v", version)
+		}
+		if !applied {
+			return nil
+		}
+		return m.record(migration, false)
+	})
+}
+
+// migration gets the migration with version
+func (m *Migrator) migration(version uint64) (Migration, error) {
+	idx := sort.Search(len(m.migrations), func(i int) bool {
+		return m.migrations[i].Version >= version
+	})
+	if idx == len(m.migrations) || m.migrations[idx].Version != version {
+		return Migration{}, errors.Errorf("there is no migration with version %v", version)
+	}
+	return m.migrations[idx], nil
+}
+
+// planUp gets the pending migrations up to target in order
+func (m *Migrator) planUp(rows map[uint64]applied, target uint64) []Step {
+	var steps []Step
+	for _, migration := range m.migrations {
+		if _, ok := rows[migration.Version]; !ok && migration.Version <= target {
+			steps = append(steps, Step{Migration: migration, Direction: Up})
+		}
+	}
+	return steps
+}
+
+// planDown gets the applied migrations after target from the newest to the oldest, every one of them needs a
+// down script for the plan to be valid
+func (m *Migrator) planDown(rows map[uint64]applied, target uint64) ([]Step, error) {
+	known := make(map[uint64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	var steps []Step
+	for version, row := range rows {
+		if version <= target {
+			continue
+		}
+		migration, ok := known[version]
+		switch {
+		case !ok:
+			return nil, &MigrationError{Version: version, Name: row.Name, Direction: Down, Err: ErrUnknownVersion}
+		case len(migration.Down) == 0:
+			return nil, &MigrationError{Version: version, Name: row.Name, Direction: Down, Err: ErrNoDownScript}
+		}
+		steps = append(steps, Step{Migration: migration, Direction: Down})
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Version > steps[j].Version
+	})
+	return steps, nil
+}
+
+// migrate plans and runs the steps while holding the lock, the plan is made once the lock is held so it reflects
+// what other migrators did in the meantime
+func (m *Migrator) migrate(plan func(rows map[uint64]applied) ([]Step, error)) (steps []Step, err error) {
+	if m.opts.dryRun {
+		rows, err := m.verified()
+		if err != nil {
+			return nil, err
+		}
+		return plan(rows)
+	}
+	err = m.locked(func(owned bool) error {
+		if err := m.createTable(); err != nil {
+			return err
+		}
+		rows, err := m.verified()
+		if err != nil {
+			return err
+		}
+		planned, err := plan(rows)
+		if err != nil {
+			return err
+		}
+		for _, step := range planned {
+			if err = m.run(step, owned); err != nil {
+				return err
+			}
+			steps = append(steps, step)
+		}
+		return nil
+	})
+	return steps, err
+}
+
+// createTable creates the migrations table if it does not exist yet
+func (m *Migrator) createTable() error {
+	if _, err := m.db.Exec("CREATE TABLE IF NOT EXISTS " + m.table() + " (" +
+		"`version` BIGINT UNSIGNED NOT NULL PRIMARY KEY, " +
+		"`name` VARCHAR(255) NOT NULL, " +
+		"`checksum` CHAR(64) NOT NULL, " +
+		"`applied_at` DATETIME(6) NOT NULL, " +
+		"`dirty` BOOLEAN NOT NULL DEFAULT FALSE)"); err != nil {
+		return errors.Wrapf(err, "creating %v", m.opts.table)
+	}
+	return nil
+}
+
+// locked runs f while holding the migration lock. The lock belongs to the connection it was taken on, so unless the
+// instance is already in one a transaction is held open to keep every statement on the same connection, owned is
+// set when that is the case so the progress of each migration gets committed as it is made.
+func (m *Migrator) locked(f func(owned bool) error) (err error) {
+	owned := !m.db.InTx()
+	if owned {
+		if err = m.db.BeginTx(nil); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				// DDL commits implicitly, this only undoes the statements of a failed migration that did not.
+				// The migration was marked as dirty beforehand, so it is caught either way.
+				_ = m.db.Rollback()
+				return
+			}
+			err = m.db.Commit()
+		}()
+	}
+	name, err := m.lockName()
+	if err != nil {
+		return err
+	}
+	lock, err := mysql.Get[sql.NullInt64](m.db, "SELECT GET_LOCK(?, ?)", name, int(m.opts.lockTimeout/time.Second))
+	if err != nil {
+		return errors.Wrap(err, "taking the migration lock")
+	}
+	if !lock.Valid || lock.Int64 != 1 {
+		return ErrLockTimeout
+	}
+	defer func() {
+		_, releaseErr := mysql.Get[sql.NullInt64](m.db, "SELECT RELEASE_LOCK(?)", name)
+		if releaseErr != nil && err == nil {
+			err = errors.Wrap(releaseErr, "releasing the migration lock")
+		}
+	}()
+	return f(owned)
+}
+
+// lockName gets the name of the migration lock, locks are server wide so the database is part of it.
+// Names longer than GET_LOCK accepts are hashed.
+func (m *Migrator) lockName() (string, error) {
+	database, err := mysql.Get[string](m.db, "SELECT IFNULL(DATABASE(), '')")
+	if err != nil {
+		return "", errors.Wrap(err, "naming the migration lock")
+	}
+	name := database + "." + m.opts.table
+	if len(name) > maxLockName {
+		sum := sha256.Sum256([]byte(name))
+		name = hex.EncodeToString(sum[:])
+	}
+	return name, nil
+}
+
+// run runs a step and records it. The step is marked as dirty while it runs, so a failure that left the schema
+// half migrated stops later runs until it is resolved with Force.
+func (m *Migrator) run(step Step, owned bool) error {
+	script := step.Up
+	if step.Direction == Down {
+		script = step.Down
+	}
+	var err error
+	if step.Direction == Up {
+		err = m.record(step.Migration, true)
+	} else {
+		_, err = m.db.Exec("UPDATE "+m.table()+" SET `dirty` = TRUE WHERE `version` = ?", step.Version)
+	}
+	if err == nil && owned {
+		err = m.db.PartialCommit()
+	}
+	if err == nil {
+		err = mysql.ExecScript(m.db, strings.NewReader(script))
+	}
+	if err == nil {
+		if step.Direction == Up {
+			_, err = m.db.Exec("UPDATE "+m.table()+" SET `dirty` = FALSE, `applied_at` = ? WHERE `version` = ?", time.Now().UTC(), step.Version)
+		} else {
+			_, err = m.db.Exec("DELETE FROM "+m.table()+" WHERE `version` = ?", step.Version)
+		}
+	}
+	if err == nil && owned {
+		err = m.db.PartialCommit()
+	}
+	if err != nil {
+		return &MigrationError{Version: step.Version, Name: step.Name, Direction: step.Direction, Err: err}
+	}
+	return nil
+}
+
+// record inserts a row for migration
+func (m *Migrator) record(migration Migration, dirty bool) error {
+	_, err := m.db.Exec("INSERT INTO "+m.table()+" (`version`, `name`, `checksum`, `applied_at`, `dirty`) VALUES (?, ?, ?, ?, ?)",
+		migration.Version, migration.Name, migration.Checksum, time.Now().UTC(), dirty)
+	return err
+}
+
+// verified gets the applied migrations, failing if any of them failed part way or were modified since
+func (m *Migrator) verified() (map[uint64]applied, error) {
+	rows, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Dirty {
+			return nil, errors.Wrapf(ErrDirty, "migration %v_%v", row.Version, row.Name)
+		}
+	}
+	for _, migration := range m.migrations {
+		if row, ok := rows[migration.Version]; ok && row.Checksum != migration.Checksum {
+			return nil, &ChecksumError{Version: migration.Version, Name: migration.Name, Applied: row.Checksum, Checksum: migration.Checksum}
+		}
+	}
+	return rows, nil
+}
+
+// applied gets the applied migrations by version, nothing has been applied if the table does not exist yet
+func (m *Migrator) applied() (map[uint64]applied, error) {
+	rows, err := mysql.Select[applied](m.db, "SELECT `version`, `name`, `checksum`, `applied_at`, `dirty` FROM "+m.table())
+	if mysql.IsUnknownTable(err) {
+		return map[uint64]applied{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %v", m.opts.table)
+	}
+	out := make(map[uint64]applied, len(rows))
+	for _, row := range rows {
+		out[row.Version] = row
+	}
+	return out, nil
+}
+
+// table gets the quoted name of the migrations table
+func (m *Migrator) table() string {
+	return "`" + strings.ReplaceAll(m.opts.table, "`", "``") + "`"
+}
@@ -0,0 +1,393 @@
+// Package mysqltest creates disposable databases for tests.
+//
+//	db := mysqltest.NewTestDB(t, mysqltest.WithSchemaFiles("db/schema.sql"))
+//	instance := db.Instance(t)
+//
+// Every database gets a random name so tests can run in parallel, it is dropped when the test completes.
+// Creating a database per test is slow for large schemas, a database can instead be shared between tests that each
+// run inside a transaction which is rolled back when they complete:
+//
+//	tx := db.Isolated(t)
+//	instance := tx.Instance(t)
+package mysqltest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+// DefaultShutdownTimeout is how long the scenes of a test database are given to complete when the test ends
+const DefaultShutdownTimeout = time.Second * 5
+
+// script is a named SQL script, read lazily so that options don't fail on their own
+type script struct {
+	name string
+	read func() ([]byte, error)
+}
+
+type options struct {
+	cfg          *mysql.MySQLConfig
+	schemas      []script
+	fixtures     []script
+	providerOpts []mysql.ProviderOption
+	sceneCfg     scene.Config
+}
+
+// Option customizes a database created by NewTestDB
+type Option func(opts *options)
+
+// WithConfig sets the server to create the database on, by default ConfigFromEnv is used.
+// The schema name is used to connect before the test database exists.
+func WithConfig(cfg mysql.MySQLConfig) Option {
+	return func(opts *options) {
+		opts.cfg = &cfg
+	}
+}
+
+// WithSchemaFiles loads the schema from files on disk, in the order given
+func WithSchemaFiles(paths ...string) Option {
+	return func(opts *options) {
+		opts.schemas = append(opts.schemas, fileScripts(paths)...)
+	}
+}
+
+// WithSchemaFS loads the schema from the files in fsys matching the glob patterns, in lexical order per pattern
+func WithSchemaFS(fsys fs.FS, patterns ...string) Option {
+	return func(opts *options) {
+		opts.schemas = append(opts.schemas, fsScripts(fsys, patterns)...)
+	}
+}
+
+// WithFixtureFiles loads fixtures from files on disk once the schema has been loaded, in the order given
+func WithFixtureFiles(paths ...string) Option {
+	return func(opts *options) {
+		opts.fixtures = append(opts.fixtures, fileScripts(paths)...)
+	}
+}
+
+// WithFixtureFS loads fixtures from the files in fsys matching the glob patterns once the schema has been loaded
+func WithFixtureFS(fsys fs.FS, patterns ...string) Option {
+	return func(opts *options) {
+		opts.fixtures = append(opts.fixtures, fsScripts(fsys, patterns)...)
+	}
+}
+
+// WithProviderOptions customizes the provider wired to the test database
+func WithProviderOptions(providerOpts ...mysql.ProviderOption) Option {
+	return func(opts *options) {
+		opts.providerOpts = append(opts.providerOpts, providerOpts...)
+	}
+}
+
+// WithSceneConfig customizes the scene factory wired to the test database
+func WithSceneConfig(cfg scene.Config) Option {
+	return func(opts *options) {
+		opts.sceneCfg = cfg
+	}
+}
+
+func fileScripts(paths []string) []script {
+	var out []script
+	for _, path := range paths {
+		path := path
+		out = append(out, script{name: path, read: func() ([]byte, error) {
+			return os.ReadFile(path)
+		}})
+	}
+	return out
+}
+
+func fsScripts(fsys fs.FS, patterns []string) []script {
+	var out []script
+	for _, pattern := range patterns {
+		pattern := pattern
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil || len(matches) == 0 {
+			out = append(out, script{name: pattern, read: func() ([]byte, error) {
+				if err == nil {
+					err = errors.Errorf("no files matched %v", pattern)
+				}
+				return nil, err
+			}})
+			continue
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			match := match
+			out = append(out, script{name: match, read: func() ([]byte, error) {
+				return fs.ReadFile(fsys, match)
+			}})
+		}
+	}
+	return out
+}
+
+// ConfigFromEnv gets the server configuration from MYSQL_HOST, MYSQL_PORT, MYSQL_USER and MYSQL_PASSWORD,
+// defaulting to root:test@127.0.0.1:3306
+func ConfigFromEnv() mysql.MySQLConfig {
+	env := func(key, def string) string {
+		if val, ok := os.LookupEnv(key); ok {
+			return val
+		}
+		return def
+	}
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseHost = env("MYSQL_HOST", "127.0.0.1")
+	cfg.DatabasePort = env("MYSQL_PORT", "3306")
+	cfg.DatabaseUserName = env("MYSQL_USER", "root")
+	// MySQL generally won't let you set up root w/o a password
+	cfg.DatabasePassword = env("MYSQL_PASSWORD", "test")
+	cfg.DatabaseSchemaName = "mysql"
+	return cfg
+}
+
+// DB is a disposable database along with a provider and scene factory wired to it
+type DB struct {
+	*sqlx.DB
+	// Name is the randomly generated schema name
+	Name string
+	// Config connects to the test database
+	Config   mysql.MySQLConfig
+	Provider mysql.Provider
+	Factory  *scene.Factory
+	opts     options
+	admin    *sqlx.DB
+	close    sync.Once
+	closeErr error
+}
+
+// NewTestDB creates a database with a random name, loads the schema and fixtures and wires up a provider and scene
+// factory. Everything is torn down and the database is dropped through tb.Cleanup, provider errors fail the test.
+func NewTestDB(tb testing.TB, opts ...Option) *DB {
+	tb.Helper()
+	out, err := open(testLogger{tb: tb}, opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := out.Close(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return out
+}
+
+// Open creates a database like NewTestDB that outlives a single test, such as a template database shared through
+// Isolated that is created in TestMain. Close must be called to drop it, provider errors are logged to stderr.
+func Open(opts ...Option) (*DB, error) {
+	return open(stdLogger{}, opts)
+}
+
+func open(logger errorLogger, opts []Option) (*DB, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := ConfigFromEnv()
+	if o.cfg != nil {
+		cfg = *o.cfg
+	}
+	admin, err := sqlx.Connect("mysql", cfg.BuildDSN())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to DB (did you start it before running tests?)")
+	}
+	out := &DB{
+		Name:   "test_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Config: cfg,
+		opts:   o,
+		admin:  admin,
+	}
+	out.Config.DatabaseSchemaName = out.Name
+	if _, err = admin.Exec(fmt.Sprintf("CREATE DATABASE `%s`", out.Name)); err != nil {
+		_ = admin.Close()
+		return nil, err
+	}
+	if err = out.setup(logger); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// setup connects to the created database, loads it and wires up the provider and factory
+func (d *DB) setup(logger errorLogger) (err error) {
+	if d.DB, err = sqlx.Connect("mysql", d.Config.BuildDSN()); err != nil {
+		return err
+	}
+	if err = d.load(append(d.opts.schemas, d.opts.fixtures...)); err != nil {
+		return err
+	}
+	if d.Provider, err = mysql.NewSceneProvider(d.Config, logger, append([]mysql.ProviderOption{mysql.WithDB(d.DB)}, d.opts.providerOpts...)...); err != nil {
+		return err
+	}
+	d.Factory, err = scene.NewSceneFactory(d.opts.sceneCfg, d.Provider)
+	return err
+}
+
+// Instance creates a scene and gets the instance the provider stored in it, the scene completes when the test ends
+func (d *DB) Instance(tb testing.TB) *mysql.Instance {
+	tb.Helper()
+	return instance(tb, d.Scene(tb), d.Provider)
+}
+
+// Scene creates a scene from the factory, it completes when the test ends
+func (d *DB) Scene(tb testing.TB) scene.Context {
+	tb.Helper()
+	return newScene(tb, d.Factory)
+}
+
+// Close shuts down the factory, closes the pool and drops the database. Databases from NewTestDB register this
+// with tb.Cleanup so calling it is only needed to drop the database early.
+func (d *DB) Close() error {
+	d.close.Do(func() {
+		if d.Factory != nil && !d.Factory.Shutdown(DefaultShutdownTimeout) {
+			d.closeErr = errors.Errorf("scenes of %v did not complete in time", d.Name)
+		}
+		if d.DB != nil {
+			_ = d.DB.Close()
+		}
+		if _, err := d.admin.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", d.Name)); err != nil && d.closeErr == nil {
+			d.closeErr = errors.Wrapf(err, "dropping %v", d.Name)
+		}
+		_ = d.admin.Close()
+	})
+	return d.closeErr
+}
+
+// TxDB is a transaction on a shared database that is rolled back when the test ends, so tests can share one
+// database (and its schema) without seeing each other's writes. Every instance handed out by its provider runs
+// inside the transaction and transactions started through them become savepoints, see mysql.WithTestTx.
+// Statements that implicitly commit, such as DDL, end the isolation.
+type TxDB struct {
+	Tx       *sqlx.Tx
+	Provider mysql.Provider
+	Factory  *scene.Factory
+}
+
+// Isolated begins a transaction on the database and wires up a provider and scene factory that run inside it,
+// the transaction is rolled back through tb.Cleanup. The provider uses the options the database was created with.
+func (d *DB) Isolated(tb testing.TB) *TxDB {
+	tb.Helper()
+	tx, err := d.DB.Beginx()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// Cleanups run last in first out, so this runs after the factory is shut down
+	tb.Cleanup(func() {
+		if err := tx.Rollback(); err != nil {
+			tb.Errorf("rolling back the test transaction on %v: %v", d.Name, err)
+		}
+	})
+	out := &TxDB{Tx: tx}
+	opts := append([]mysql.ProviderOption{mysql.WithDB(d.DB)}, d.opts.providerOpts...)
+	if out.Provider, err = mysql.NewSceneProvider(d.Config, testLogger{tb: tb}, append(opts, mysql.WithTestTx(tx))...); err != nil {
+		tb.Fatal(err)
+	}
+	if out.Factory, err = scene.NewSceneFactory(d.opts.sceneCfg, out.Provider); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if !out.Factory.Shutdown(DefaultShutdownTimeout) {
+			tb.Errorf("scenes of %v did not complete in time", d.Name)
+		}
+	})
+	return out
+}
+
+// Instance creates a scene and gets the instance the provider stored in it, the scene completes when the test ends
+func (d *TxDB) Instance(tb testing.TB) *mysql.Instance {
+	tb.Helper()
+	return instance(tb, d.Scene(tb), d.Provider)
+}
+
+// Scene creates a scene from the factory, it completes when the test ends
+func (d *TxDB) Scene(tb testing.TB) scene.Context {
+	tb.Helper()
+	return newScene(tb, d.Factory)
+}
+
+func newScene(tb testing.TB, factory *scene.Factory) scene.Context {
+	tb.Helper()
+	ctx, err := factory.NewCtx()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(ctx.Complete)
+	return ctx
+}
+
+func instance(tb testing.TB, ctx scene.Context, provider mysql.Provider) *mysql.Instance {
+	tb.Helper()
+	out := mysql.GetManagedDatabaseInstanceByKey(ctx, provider.Key())
+	if out == nil {
+		tb.Fatal("the provider did not provide an instance")
+	}
+	return out
+}
+
+func (d *DB) load(scripts []script) error {
+	if len(scripts) == 0 {
+		return nil
+	}
+	// Scripts run on a single connection so session settings carry between their statements
+	loader, err := sqlx.Connect("mysql", d.Config.BuildDSN())
+	if err != nil {
+		return err
+	}
+	defer loader.Close()
+	loader.SetMaxOpenConns(1)
+	instance := mysql.NewInstance(context.Background(), loader)
+	for _, s := range scripts {
+		data, err := s.read()
+		if err != nil {
+			return errors.Wrapf(err, "reading %v", s.name)
+		}
+		if err = mysql.ExecScript(instance, bytes.NewReader(data), mysql.SkipStatements(changesDatabase)); err != nil {
+			return errors.Wrapf(err, "loading %v", s.name)
+		}
+	}
+	return nil
+}
+
+// changesDatabase checks if a statement would create or switch to another database than the one being loaded
+func changesDatabase(statement mysql.ScriptStatement) bool {
+	upper := strings.ToUpper(statement.Query)
+	return strings.HasPrefix(upper, "USE ") || strings.HasPrefix(upper, "CREATE DATABASE ")
+}
+
+// errorLogger is what a provider logs to
+type errorLogger interface {
+	Errorf(format string, v ...interface{})
+}
+
+// testLogger fails the test with provider errors
+type testLogger struct {
+	tb testing.TB
+}
+
+func (l testLogger) Errorf(format string, v ...interface{}) {
+	l.tb.Helper()
+	l.tb.Errorf(format, v...)
+}
+
+// stdLogger logs provider errors to stderr for databases that are not tied to a test
+type stdLogger struct{}
+
+func (stdLogger) Errorf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
@@ -0,0 +1,79 @@
+package mysqltest_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/mysqltest"
+)
+
+var migrations = fstest.MapFS{
+	"schema/001_kvp.sql": {Data: []byte("CREATE TABLE `test_kvp` (`key` VARCHAR(191) NOT NULL PRIMARY KEY, `val` VARCHAR(191) NOT NULL);")},
+	"schema/002_log.sql": {Data: []byte("# Depends on 001\nALTER TABLE `test_kvp` ADD COLUMN `log` TEXT NULL;")},
+	"fixtures/kvp.sql":   {Data: []byte("INSERT INTO `test_kvp` (`key`, `val`) VALUES ('a', '1'), ('b', '2');")},
+}
+
+func TestNewTestDB(t *testing.T) {
+	for _, name := range []string{"first", "second"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			db := mysqltest.NewTestDB(t,
+				mysqltest.WithSchemaFS(migrations, "schema/*.sql"),
+				mysqltest.WithFixtureFS(migrations, "fixtures/*.sql"),
+				mysqltest.WithProviderOptions(mysql.WithName("kvp")),
+			)
+			instance := db.Instance(t)
+			kvps, err := mysql.Select[struct {
+				Key string  `db:"key"`
+				Val string  `db:"val"`
+				Log *string `db:"log"`
+			}](instance, "SELECT * FROM test_kvp ORDER BY `key`")
+			require.NoError(t, err)
+			require.Len(t, kvps, 2)
+			require.Equal(t, "1", kvps[0].Val)
+			_, err = instance.Exec("INSERT INTO test_kvp (`key`, `val`) VALUES (?, ?)", name, name)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDB_Close(t *testing.T) {
+	db := mysqltest.NewTestDB(t)
+	require.NoError(t, db.Close())
+	other := mysqltest.NewTestDB(t)
+	var ct int
+	require.NoError(t, other.Get(&ct, "SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", db.Name))
+	require.Equal(t, 0, ct)
+	require.NoError(t, db.Close(), "closing is idempotent")
+}
+
+func TestDB_Isolated(t *testing.T) {
+	db := mysqltest.NewTestDB(t,
+		mysqltest.WithSchemaFS(migrations, "schema/*.sql"),
+		mysqltest.WithFixtureFS(migrations, "fixtures/*.sql"),
+	)
+	count := func(t *testing.T, instance *mysql.Instance) int {
+		ct, err := mysql.Get[int](instance, "SELECT COUNT(*) FROM test_kvp")
+		require.NoError(t, err)
+		return ct
+	}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			tx := db.Isolated(t)
+			instance := tx.Instance(t)
+			require.Equal(t, 2, count(t, instance), "writes of other tests are rolled back")
+			require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+				_, err := db.Exec("INSERT INTO test_kvp (`key`, `val`) VALUES (?, ?)", name, name)
+				return err
+			}))
+			require.Equal(t, 3, count(t, tx.Instance(t)), "scenes share the transaction")
+		})
+	}
+	var ct int
+	require.NoError(t, db.Get(&ct, "SELECT COUNT(*) FROM test_kvp"))
+	require.Equal(t, 2, ct)
+}
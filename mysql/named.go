@@ -0,0 +1,30 @@
+package mysql
+
+import "database/sql"
+
+// NamedExec binds :name parameters from a struct or map and runs the statement like Exec
+func (d *Instance) NamedExec(query string, arg any) (sql.Result, error) {
+	bound, args, err := d.db.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return d.Exec(bound, args...)
+}
+
+// NamedQuery binds :name parameters from a struct or map and runs the query like Queryx
+func (d *Instance) NamedQuery(query string, arg any) (*Rowsx, error) {
+	bound, args, err := d.db.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return d.queryx(1, bound, args...)
+}
+
+// NamedGet binds :name parameters from a struct or map and scans the first row into a T like Get
+func NamedGet[T any](db *Instance, query string, arg any) (out T, err error) {
+	bound, args, err := db.db.BindNamed(query, arg)
+	if err != nil {
+		return out, err
+	}
+	return get[T](db, 1, bound, args...)
+}
@@ -0,0 +1,53 @@
+package mysql_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+func TestInstance_NamedExec(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.NamedExec("INSERT INTO test_kvp (`key`,`val`) VALUES(:key,:val)", testKVP{Key: "test", Val: "test"})
+	require.NoError(t, err)
+	_, err = instance.NamedExec("INSERT INTO test_kvp (`key`,`val`) VALUES(:key,:val)", map[string]any{"key": "test2", "val": "test2"})
+	require.NoError(t, err)
+	_, err = instance.NamedExec("INSERT INTO test_kvp (`key`,`val`) VALUES(:key,:missing)", testKVP{Key: "test3"})
+	require.Error(t, err)
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		_, err := db.NamedExec("INSERT INTO test_kvp (`key`,`val`) VALUES(:key,:val)", testKVP{Key: "test3", Val: "test3"})
+		require.NoError(t, err)
+		var ct int
+		require.NoError(t, db.Isolate().QueryRow("SELECT count(*) FROM test_kvp").Scan(&ct))
+		require.Equal(t, 2, ct)
+		return nil
+	}))
+}
+
+func TestInstance_NamedQuery(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2')")
+	require.NoError(t, err)
+	rows, err := instance.NamedQuery("SELECT `key`,`val` FROM test_kvp WHERE `val` = :val", map[string]any{"val": "test2"})
+	require.NoError(t, err)
+	_, err = instance.Query("SELECT 1")
+	require.ErrorIs(t, err, mysql.ErrRowsNotClosed)
+	require.ErrorContains(t, err, "named_test.go")
+	require.True(t, rows.Next())
+	var kvp testKVP
+	require.NoError(t, rows.StructScan(&kvp))
+	require.Equal(t, "test2", kvp.Key)
+	require.NoError(t, rows.Close())
+}
+
+func TestNamedGet(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2')")
+	require.NoError(t, err)
+	kvp, err := mysql.NamedGet[testKVP](instance, "SELECT `key`,`val` FROM test_kvp WHERE `key` = :key", testKVP{Key: "test"})
+	require.NoError(t, err)
+	require.Equal(t, testKVP{Key: "test", Val: "test"}, kvp)
+	_, err = mysql.NamedGet[testKVP](instance, "SELECT `key`,`val` FROM test_kvp WHERE `key` = :key", testKVP{Key: "missing"})
+	require.True(t, mysql.IsNoRows(err))
+}
@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/weisbartb/scene"
+)
+
+// ProviderOption customizes a provider created through NewSceneProvider
+type ProviderOption func(provider *Provider)
+
+// InstanceHook is invoked with every instance a provider creates for a new scene
+type InstanceHook func(ctx scene.Context, instance *Instance)
+
+// InstanceCompleteHook is invoked when a scene completes, before its instance is closed
+type InstanceCompleteHook func(ctx scene.Context, instance *Instance, completeErr error)
+
+// WithDB uses an existing pool instead of connecting to the configured database.
+// The pool is not closed on shutdown unless WithCloseOnShutdown(true) is also provided.
+func WithDB(db *sqlx.DB) ProviderOption {
+	return func(provider *Provider) {
+		provider.DB = db
+	}
+}
+
+// WithReplicas uses an existing replica pool instead of connecting to the configured replica hosts
+func WithReplicas(replicas *ReplicaPool) ProviderOption {
+	return func(provider *Provider) {
+		provider.Replicas = replicas
+	}
+}
+
+// WithCloseOnShutdown decides if the pools are closed when the factory unmounts
+func WithCloseOnShutdown(closeOnShutdown bool) ProviderOption {
+	return func(provider *Provider) {
+		provider.closeOnShutdown = closeOnShutdown
+		provider.closeOnShutdownSet = true
+	}
+}
+
+// WithShutdownTimeout sets the deadline the provider gets when the factory unmounts, work in flight is drained first
+// and the pools are closed in whatever is left of it. Work still in flight once it passes is force closed.
+func WithShutdownTimeout(timeout time.Duration) ProviderOption {
+	return func(provider *Provider) {
+		provider.shutdownTimeout = timeout
+	}
+}
+
+// WithName registers the provider under a name so that several databases can be provided to the same scene factory.
+// Instances are retrieved with GetManagedDatabaseInstanceNamed.
+func WithName(name string) ProviderOption {
+	return func(provider *Provider) {
+		provider.name = name
+		if len(name) > 0 {
+			provider.contextKey = CtxNamedContextKey{Name: name}
+		}
+	}
+}
+
+// WithContextKey stores instances under a custom context key, they can be retrieved with
+// GetManagedDatabaseInstanceByKey.
+func WithContextKey(key any) ProviderOption {
+	return func(provider *Provider) {
+		provider.contextKey = key
+	}
+}
+
+// WithCommitOnSuccess gives every scene unit of work semantics, see Provider.CommitOnSuccess
+func WithCommitOnSuccess(commitOnSuccess bool) ProviderOption {
+	return func(provider *Provider) {
+		provider.CommitOnSuccess = commitOnSuccess
+	}
+}
+
+// WithNewInstanceHook registers a hook that runs for every instance created for a new scene
+func WithNewInstanceHook(hook InstanceHook) ProviderOption {
+	return func(provider *Provider) {
+		provider.onNewInstance = append(provider.onNewInstance, hook)
+	}
+}
+
+// WithCompleteInstanceHook registers a hook that runs when a scene completes, before its instance is closed
+func WithCompleteInstanceHook(hook InstanceCompleteHook) ProviderOption {
+	return func(provider *Provider) {
+		provider.onCompleteInstance = append(provider.onCompleteInstance, hook)
+	}
+}
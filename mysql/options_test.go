@@ -0,0 +1,92 @@
+package mysql_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+type analyticsKey struct{}
+
+func TestNewSceneProvider_Options(t *testing.T) {
+	db := internal.OpenFakeDB(t, "options")
+	var created, completed []*mysql.Instance
+	factory, provider := internal.NewSceneFactory(t, nil,
+		mysql.WithDB(db),
+		mysql.WithContextKey(analyticsKey{}),
+		mysql.WithShutdownTimeout(time.Second),
+		mysql.WithNewInstanceHook(func(ctx scene.Context, instance *mysql.Instance) {
+			created = append(created, instance)
+		}),
+		mysql.WithCompleteInstanceHook(func(ctx scene.Context, instance *mysql.Instance, completeErr error) {
+			require.ErrorIs(t, completeErr, scene.ErrComplete)
+			completed = append(completed, instance)
+		}),
+	)
+	require.Equal(t, db, provider.DB)
+	ctx, err := factory.NewCtx()
+	require.NoError(t, err)
+	require.Nil(t, mysql.GetManagedDatabaseInstance(ctx))
+	instance := mysql.GetManagedDatabaseInstanceByKey(ctx, analyticsKey{})
+	require.NotNil(t, instance)
+	require.Equal(t, []*mysql.Instance{instance}, created)
+	require.Empty(t, completed)
+	ctx.Complete()
+	require.Equal(t, []*mysql.Instance{instance}, completed)
+	require.True(t, factory.Shutdown(time.Second))
+	// The pool was handed in, so it belongs to the caller
+	require.NoError(t, db.Ping())
+}
+
+func TestNewSceneProvider_CloseOnShutdown(t *testing.T) {
+	db := internal.OpenFakeDB(t, "close")
+	factory, _ := internal.NewSceneFactory(t, nil, mysql.WithDB(db), mysql.WithCloseOnShutdown(true))
+	factory.Shutdown(time.Second)
+	require.EqualError(t, db.Ping(), "sql: database is closed")
+}
+
+func TestNewSceneProvider_Named(t *testing.T) {
+	orders := internal.OpenFakeDB(t, "orders")
+	analytics := internal.OpenFakeDB(t, "analytics")
+	analyticsProvider := must(mysql.NewSceneProvider(mysql.MySQLConfig{}, nil, mysql.WithDB(analytics), mysql.WithName("analytics")))
+	require.Equal(t, "analytics", analyticsProvider.Name())
+	factory, err := scene.NewSceneFactory(scene.Config{},
+		must(mysql.NewSceneProvider(mysql.MySQLConfig{}, nil, mysql.WithDB(orders))),
+		analyticsProvider,
+	)
+	require.NoError(t, err)
+	ctx, err := factory.NewCtx()
+	require.NoError(t, err)
+	require.Equal(t, "orders", readSource(t, mysql.GetManagedDatabaseInstance(ctx)))
+	require.Equal(t, "orders", readSource(t, mysql.GetManagedDatabaseInstanceNamed(ctx, "")))
+	require.Equal(t, "analytics", readSource(t, mysql.GetManagedDatabaseInstanceNamed(ctx, "analytics")))
+	require.Nil(t, mysql.GetManagedDatabaseInstanceNamed(ctx, "billing"))
+	ordersInstance := mysql.GetManagedDatabaseInstance(ctx)
+	analyticsInstance := mysql.GetManagedDatabaseInstanceNamed(ctx, "analytics")
+	require.NoError(t, ordersInstance.BeginTx(nil))
+	require.NoError(t, analyticsInstance.BeginTx(nil))
+	ctx.Complete()
+	require.False(t, ordersInstance.InTx())
+	require.False(t, analyticsInstance.InTx())
+	require.Contains(t, internal.FakeDBStatements("orders"), "ROLLBACK")
+	require.Contains(t, internal.FakeDBStatements("analytics"), "ROLLBACK")
+}
+
+func TestNewSceneProvider_InvalidReplicaSettings(t *testing.T) {
+	// Nothing listens on the port, so the settings must be rejected before connecting
+	cfg := mysql.DefaultMySQLCfg()
+	cfg.DatabaseHost = "127.0.0.1"
+	cfg.DatabasePort = "1"
+	cfg.ReplicaHosts = []string{"127.0.0.1:1"}
+	cfg.ReplicaConsistency = "strong"
+	_, err := mysql.NewSceneProvider(cfg, nil)
+	require.ErrorIs(t, err, mysql.ErrInvalidReadConsistency)
+	cfg.ReplicaConsistency = "gtid"
+	cfg.ReplicaGTIDWaitTimeout = "soon"
+	_, err = mysql.NewSceneProvider(cfg, nil)
+	require.ErrorIs(t, err, mysql.ErrInvalidGTIDWaitTimeout)
+}
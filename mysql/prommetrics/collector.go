@@ -0,0 +1,156 @@
+// Package prommetrics exports the measurements of mysql providers to Prometheus
+package prommetrics
+
+import (
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weisbartb/scene-db/mysql"
+)
+
+// Opts customizes the metrics created by New
+type Opts struct {
+	// Namespace prefixes every metric, defaults to scene
+	Namespace string
+	// Buckets for the operation latency histogram in seconds, defaults to prometheus.DefBuckets
+	Buckets []float64
+	// TxBuckets for the transaction duration histogram in seconds, defaults to prometheus.DefBuckets
+	TxBuckets []float64
+}
+
+// Collector is a mysql.MetricsCollector that is also a prometheus.Collector, register it with both
+//
+//	collector := prommetrics.New(prommetrics.Opts{})
+//	prometheus.MustRegister(collector)
+//	provider, err := mysql.NewSceneProvider(cfg, logger, mysql.WithMetrics(collector))
+type Collector struct {
+	mu           sync.RWMutex
+	pools        map[string]func() map[string]sql.DBStats
+	operations   *prometheus.HistogramVec
+	errors       *prometheus.CounterVec
+	transactions *prometheus.HistogramVec
+	unclosedRows *prometheus.CounterVec
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+var _ mysql.MetricsCollector = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New creates a collector, it does not report anything until it is registered with a prometheus.Registerer
+func New(opts Opts) *Collector {
+	if len(opts.Namespace) == 0 {
+		opts.Namespace = "scene"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+	if opts.TxBuckets == nil {
+		opts.TxBuckets = prometheus.DefBuckets
+	}
+	const subsystem = "mysql"
+	poolDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, subsystem, name), help, []string{"provider", "pool"}, nil)
+	}
+	return &Collector{
+		pools: make(map[string]func() map[string]sql.DBStats),
+		operations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: subsystem,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of statements and transaction calls by operation.",
+			Buckets:   opts.Buckets,
+		}, []string{"provider", "operation", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: subsystem,
+			Name:      "errors_total",
+			Help:      "Failed operations by MySQL error code, unknown when the error did not come from the server.",
+		}, []string{"provider", "operation", "code"}),
+		transactions: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: subsystem,
+			Name:      "transaction_duration_seconds",
+			Help:      "How long transactions were open for by outcome.",
+			Buckets:   opts.TxBuckets,
+		}, []string{"provider", "outcome"}),
+		unclosedRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: subsystem,
+			Name:      "unclosed_rows_total",
+			Help:      "Statements refused because the rows of a previous statement were left open.",
+		}, []string{"provider"}),
+		maxOpen:      poolDesc("pool_max_open_connections", "Maximum number of open connections to the database."),
+		open:         poolDesc("pool_open_connections", "The number of established connections both in use and idle."),
+		inUse:        poolDesc("pool_in_use_connections", "The number of connections currently in use."),
+		idle:         poolDesc("pool_idle_connections", "The number of idle connections."),
+		waitCount:    poolDesc("pool_wait_count_total", "The total number of connections waited for."),
+		waitDuration: poolDesc("pool_wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+	}
+}
+
+func (c *Collector) RegisterPools(provider string, stats func() map[string]sql.DBStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[provider] = stats
+}
+
+func (c *Collector) ObserveOperation(provider string, op string, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+		code := "unknown"
+		if errCode := mysql.GetErrorCode(err); errCode != 0 {
+			code = strconv.Itoa(int(errCode))
+		}
+		c.errors.WithLabelValues(provider, op, code).Inc()
+	}
+	c.operations.WithLabelValues(provider, op, status).Observe(duration.Seconds())
+}
+
+func (c *Collector) ObserveTransaction(provider string, duration time.Duration, outcome mysql.TxOutcome) {
+	c.transactions.WithLabelValues(provider, string(outcome)).Observe(duration.Seconds())
+}
+
+func (c *Collector) ObserveUnclosedRows(provider string) {
+	c.unclosedRows.WithLabelValues(provider).Inc()
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.operations.Describe(ch)
+	c.errors.Describe(ch)
+	c.transactions.Describe(ch)
+	c.unclosedRows.Describe(ch)
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.operations.Collect(ch)
+	c.errors.Collect(ch)
+	c.transactions.Collect(ch)
+	c.unclosedRows.Collect(ch)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for provider, stats := range c.pools {
+		for pool, s := range stats() {
+			ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections), provider, pool)
+			ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections), provider, pool)
+			ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse), provider, pool)
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle), provider, pool)
+			ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount), provider, pool)
+			ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds(), provider, pool)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package prommetrics_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+	"github.com/weisbartb/scene-db/mysql/prommetrics"
+)
+
+// gather flattens every sample into name{label=value,...}, histograms are reported by their sample count
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	out := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			sort.Strings(labels)
+			key := family.GetName() + "{" + strings.Join(labels, ",") + "}"
+			switch {
+			case metric.Counter != nil:
+				out[key] = metric.GetCounter().GetValue()
+			case metric.Gauge != nil:
+				out[key] = metric.GetGauge().GetValue()
+			case metric.Histogram != nil:
+				out[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return out
+}
+
+func TestCollector(t *testing.T) {
+	collector := prommetrics.New(prommetrics.Opts{})
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+	replicas := mysql.NewReplicaPool(0, internal.OpenFakeDB(t, "metrics_replica"))
+	t.Cleanup(func() {
+		require.NoError(t, replicas.Close())
+	})
+	primary := internal.OpenFakeDB(t, "metrics")
+	primary.SetMaxOpenConns(7)
+	provider, err := mysql.NewSceneProvider(mysql.MySQLConfig{}, nil,
+		mysql.WithDB(primary), mysql.WithReplicas(replicas), mysql.WithName("orders"), mysql.WithMetrics(collector),
+	)
+	require.NoError(t, err)
+	factory, err := scene.NewSceneFactory(scene.Config{}, provider)
+	require.NoError(t, err)
+	ctx, err := factory.NewCtx()
+	require.NoError(t, err)
+	t.Cleanup(ctx.Complete)
+	instance := mysql.GetManagedDatabaseInstanceNamed(ctx, "orders")
+
+	_, err = instance.Exec("UPDATE test_kvp SET val = ?", "test")
+	require.NoError(t, err)
+	internal.SetFakeDBError("metrics", "INSERT INTO test_kvp VALUES (1)", &mysqlDriver.MySQLError{
+		Number: mysql.ErrCodeDuplicateKey,
+	})
+	_, err = instance.Exec("INSERT INTO test_kvp VALUES (1)")
+	require.Error(t, err)
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		return nil
+	}))
+	require.NoError(t, instance.BeginTx(nil))
+	require.NoError(t, instance.Rollback())
+	rows, err := instance.Query("SELECT source")
+	require.NoError(t, err)
+	_, err = instance.Exec("DELETE FROM test_kvp")
+	require.ErrorIs(t, err, mysql.ErrRowsNotClosed)
+	require.NoError(t, rows.Close())
+
+	samples := gather(t, registry)
+	require.Equal(t, 1.0, samples["scene_mysql_operation_duration_seconds{operation=Exec,provider=orders,status=ok}"])
+	require.Equal(t, 1.0, samples["scene_mysql_operation_duration_seconds{operation=Exec,provider=orders,status=error}"])
+	require.Equal(t, 1.0, samples["scene_mysql_operation_duration_seconds{operation=Query,provider=orders,status=ok}"])
+	require.Equal(t, 2.0, samples["scene_mysql_operation_duration_seconds{operation=BeginTx,provider=orders,status=ok}"])
+	require.Equal(t, 1.0, samples["scene_mysql_errors_total{code=1062,operation=Exec,provider=orders}"])
+	require.Equal(t, 1.0, samples["scene_mysql_transaction_duration_seconds{outcome=commit,provider=orders}"])
+	require.Equal(t, 1.0, samples["scene_mysql_transaction_duration_seconds{outcome=rollback,provider=orders}"])
+	require.Equal(t, 1.0, samples["scene_mysql_unclosed_rows_total{provider=orders}"])
+	require.Equal(t, 7.0, samples["scene_mysql_pool_max_open_connections{pool=primary,provider=orders}"])
+	require.Contains(t, samples, "scene_mysql_pool_open_connections{pool=replica_0,provider=orders}")
+	require.Contains(t, samples, "scene_mysql_pool_wait_count_total{pool=primary,provider=orders}")
+}
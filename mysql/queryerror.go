@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// QueryError adds the context of the operation that failed to an error, see WithQueryErrors.
+// It unwraps to the underlying error so GetErrorCode, ClassifyError and the Is helpers keep working.
+type QueryError struct {
+	Op Op
+	// Statement is normalized (see NormalizeQuery) unless QueryErrorOptions.FullStatement was set
+	Statement string
+	// Caller is the file:line the operation was called from
+	Caller string
+	InTx   bool
+	// Args describes the arguments by type without their values
+	Args string
+	Err  error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%v: %v on %v (in transaction: %v): %v args: %v", e.Err, e.Op, e.Caller, e.InTx, e.Statement, e.Args)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// QueryErrorOptions configures the errors created by WithQueryErrors
+type QueryErrorOptions struct {
+	// FullStatement keeps statements as they were written, any literals in them will end up in the error
+	FullStatement bool
+}
+
+// WithQueryErrors wraps every error returned by an operation in a *QueryError, describing the statement, the
+// caller and the transaction state. Errors of reading the results (scanning rows, Err) are wrapped as well, except
+// for sql.ErrNoRows.
+func WithQueryErrors(opts QueryErrorOptions) ProviderOption {
+	return func(provider *Provider) {
+		provider.queryErrors = &opts
+	}
+}
+
+// queryError wraps err in a *QueryError if this instance was set up to
+func (d *Instance) queryError(stmt *Statement, err error) error {
+	if err == nil || d.queryErrors == nil {
+		return err
+	}
+	var existing *QueryError
+	if errors.As(err, &existing) {
+		return err
+	}
+	statement := stmt.Query
+	if !d.queryErrors.FullStatement {
+		statement = NormalizeQuery(statement)
+	}
+	return &QueryError{
+		Op:        stmt.Op,
+		Statement: statement,
+		Caller:    externalCaller(),
+		InTx:      stmt.InTx,
+		Args:      redactArgs(stmt.Args),
+		Err:       err,
+	}
+}
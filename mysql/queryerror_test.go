@@ -0,0 +1,78 @@
+package mysql_test
+
+import (
+	"errors"
+	"testing"
+
+	md "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestProvider_QueryErrors(t *testing.T) {
+	const query = "SELECT missing FROM test_kvp WHERE `key` = 'secret' AND val = ?"
+	newInstance := func(t *testing.T, opts ...mysql.ProviderOption) *mysql.Instance {
+		db := internal.OpenFakeDB(t, "query_errors")
+		internal.SetFakeDBError("query_errors", query, &md.MySQLError{
+			Number: mysql.ErrCodeUnknownColumn, Message: "Unknown column 'missing' in 'field list'",
+		})
+		return internal.NewInstance(t, nil, append(opts, mysql.WithDB(db))...)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := newInstance(t).Query(query, "hunter2")
+		var queryErr *mysql.QueryError
+		require.False(t, errors.As(err, &queryErr))
+		require.True(t, mysql.IsUnknownColumn(err))
+	})
+	t.Run("redacted", func(t *testing.T) {
+		instance := newInstance(t, mysql.WithQueryErrors(mysql.QueryErrorOptions{}))
+		require.NoError(t, instance.BeginTx(nil))
+		err := instance.QueryRowx(query, "hunter2").Scan(new(string))
+		var queryErr *mysql.QueryError
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, mysql.OpQueryRowx, queryErr.Op)
+		require.Equal(t, "SELECT missing FROM test_kvp WHERE `key` = ? AND val = ?", queryErr.Statement)
+		require.Contains(t, queryErr.Caller, "queryerror_test.go:")
+		require.True(t, queryErr.InTx)
+		require.Equal(t, "[string(7)]", queryErr.Args)
+		require.NotContains(t, err.Error(), "secret")
+		require.NotContains(t, err.Error(), "hunter2")
+		require.Contains(t, err.Error(), "Error 1054: Unknown column 'missing' in 'field list': QueryRowx on ")
+		require.Equal(t, mysql.ErrCodeUnknownColumn, mysql.GetErrorCode(err))
+		require.True(t, mysql.IsUnknownColumn(err))
+		var mysqlErr *md.MySQLError
+		require.True(t, errors.As(err, &mysqlErr))
+	})
+	t.Run("full statement", func(t *testing.T) {
+		instance := newInstance(t, mysql.WithQueryErrors(mysql.QueryErrorOptions{FullStatement: true}))
+		_, err := mysql.Select[string](instance, query, "hunter2")
+		var queryErr *mysql.QueryError
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, query, queryErr.Statement)
+		require.Contains(t, queryErr.Caller, "queryerror_test.go:")
+		require.False(t, queryErr.InTx)
+	})
+	t.Run("results", func(t *testing.T) {
+		instance := newInstance(t, mysql.WithQueryErrors(mysql.QueryErrorOptions{}))
+		var queryErr *mysql.QueryError
+		// The fake database answers with its name, which does not scan into an int
+		err := instance.QueryRow("SELECT source FROM test_kvp WHERE `key` = 'secret'").Scan(new(int))
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, mysql.OpQueryRow, queryErr.Op)
+		require.Equal(t, "SELECT source FROM test_kvp WHERE `key` = ?", queryErr.Statement)
+		require.Contains(t, queryErr.Caller, "queryerror_test.go:")
+
+		_, err = mysql.Get[int](instance, "SELECT source")
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, mysql.OpQueryx, queryErr.Op)
+		require.Contains(t, queryErr.Caller, "queryerror_test.go:")
+
+		err = instance.QueryFor("SELECT source").For(func(row mysql.Scannable) error {
+			return row.Scan(new(int))
+		})
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, mysql.OpQuery, queryErr.Op)
+	})
+}
@@ -0,0 +1,243 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DefaultReplicaHealthCheckInterval is how often replicas are pinged to decide if they should receive reads
+const DefaultReplicaHealthCheckInterval = time.Second * 5
+
+// DefaultGTIDWaitTimeout is how long a replica is given to apply a scene's writes before the read goes to the primary
+const DefaultGTIDWaitTimeout = time.Second
+
+var ErrInvalidReadConsistency = errors.New("invalid read consistency provided")
+
+// ReadConsistency decides where reads go once an instance has written to the primary.
+// Writes count for the instance's whole scene, its children included (see Instance.SpawnChild), but not for isolated
+// instances.
+type ReadConsistency int
+
+const (
+	// EventualConsistency keeps balancing reads across replicas after a write, they may return stale data
+	EventualConsistency ReadConsistency = iota
+	// PrimaryAfterWrite pins every read to the primary once the scene has written
+	PrimaryAfterWrite
+	// WaitForGTID captures the primary's executed GTID set after a write and waits for the chosen replica to apply it
+	// (up to the GTIDWaitTimeout) before reading from it, falling back to the primary if it doesn't catch up in time.
+	WaitForGTID
+)
+
+// ParseReadConsistency parses a read consistency from its config value (eventual, primary or gtid)
+func ParseReadConsistency(value string) (ReadConsistency, error) {
+	switch value {
+	case "", "eventual":
+		return EventualConsistency, nil
+	case "primary":
+		return PrimaryAfterWrite, nil
+	case "gtid":
+		return WaitForGTID, nil
+	}
+	return EventualConsistency, ErrInvalidReadConsistency
+}
+
+type replica struct {
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// ReplicaPool balances reads across a set of read replicas.
+// Replicas that fail a health check are ejected until they pass one again.
+type ReplicaPool struct {
+	// Consistency decides where reads go once an instance has written to the primary
+	Consistency ReadConsistency
+	// GTIDWaitTimeout bounds how long a read waits on a replica under WaitForGTID (defaults to DefaultGTIDWaitTimeout)
+	GTIDWaitTimeout time.Duration
+	replicas        []*replica
+	counter         atomic.Uint64
+	stop            chan struct{}
+	stopOnce        sync.Once
+	wg              sync.WaitGroup
+	pingLimit       time.Duration
+}
+
+// NewReplicaPool creates a pool over the given replica connections and runs an initial health check, which blocks
+// until every replica answered a ping or the 2s ping limit passed for the ones that did not.
+// If interval is greater than 0, health checks are re-run in the background on that interval until Close is called.
+func NewReplicaPool(interval time.Duration, dbs ...*sqlx.DB) *ReplicaPool {
+	pool := &ReplicaPool{
+		GTIDWaitTimeout: DefaultGTIDWaitTimeout,
+		stop:            make(chan struct{}),
+		pingLimit:       time.Second * 2,
+	}
+	for _, db := range dbs {
+		pool.replicas = append(pool.replicas, &replica{db: db})
+	}
+	pool.CheckHealth()
+	if interval > 0 {
+		pool.wg.Add(1)
+		go pool.monitor(interval)
+	}
+	return pool
+}
+
+func (p *ReplicaPool) monitor(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+// CheckHealth pings every replica, ejecting the ones that fail and re-admitting the ones that recovered.
+// Replicas are pinged concurrently, so an unreachable one costs the ping limit once rather than once per replica.
+func (p *ReplicaPool) CheckHealth() {
+	var wg sync.WaitGroup
+	for _, r := range p.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.pingLimit)
+			defer cancel()
+			r.healthy.Store(r.db.PingContext(ctx) == nil)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// Healthy gets the number of replicas currently receiving reads
+func (p *ReplicaPool) Healthy() int {
+	var ct int
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			ct++
+		}
+	}
+	return ct
+}
+
+// Stats gets the connection stats of every replica, in the order they were provided
+func (p *ReplicaPool) Stats() []sql.DBStats {
+	out := make([]sql.DBStats, 0, len(p.replicas))
+	for _, r := range p.replicas {
+		out = append(out, r.db.Stats())
+	}
+	return out
+}
+
+// next picks the next healthy replica in a round-robin fashion, nil is returned if none are healthy
+func (p *ReplicaPool) next() *sqlx.DB {
+	total := uint64(len(p.replicas))
+	if total == 0 {
+		return nil
+	}
+	start := p.counter.Add(1)
+	for i := uint64(0); i < total; i++ {
+		if r := p.replicas[(start+i)%total]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+// Close stops the health checks and closes every replica connection
+func (p *ReplicaPool) Close() error {
+	var err error
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		for _, r := range p.replicas {
+			if closeErr := r.db.Close(); closeErr != nil && err == nil {
+				err = errors.Wrap(closeErr, "closing replica")
+			}
+		}
+	})
+	return err
+}
+
+// writeState records the writes a scene made to the primary
+type writeState struct {
+	mu          sync.Mutex
+	wrote       bool
+	gtidPending bool
+	gtid        string
+	caughtUp    map[*sqlx.DB]struct{}
+}
+
+func (w *writeState) markWritten() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.wrote = true
+	w.gtidPending = true
+}
+
+func (w *writeState) hasWritten() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.wrote
+}
+
+// noteWrite records that the instance wrote to the primary, writes in a transaction only count once it commits
+func (d *Instance) noteWrite() {
+	if d.replicas == nil {
+		return
+	}
+	if d.tx != nil {
+		d.txWrote = true
+		return
+	}
+	d.writes.markWritten()
+}
+
+// noteCommit promotes the writes of a committed transaction
+func (d *Instance) noteCommit() {
+	if d.txWrote {
+		d.txWrote = false
+		d.writes.markWritten()
+	}
+}
+
+// caughtUpReplica gets a replica that has applied every write the scene made, or the primary if none caught up.
+// The state is locked while waiting so that instances of the same scene do not wait on the same replica twice.
+func (d *Instance) caughtUpReplica() *sqlx.DB {
+	w := d.writes
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.gtidPending {
+		if err := d.db.QueryRowContext(d.ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&w.gtid); err != nil {
+			return d.db
+		}
+		w.gtidPending = false
+		w.caughtUp = nil
+	}
+	db := d.replicas.next()
+	if db == nil {
+		return d.db
+	}
+	if _, found := w.caughtUp[db]; found {
+		return db
+	}
+	// Returns 0 once the GTID set has been applied and 1 on a timeout
+	var timedOut int
+	err := db.QueryRowContext(d.ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", w.gtid, d.replicas.GTIDWaitTimeout.Seconds()).Scan(&timedOut)
+	if err != nil || timedOut != 0 {
+		return d.db
+	}
+	if w.caughtUp == nil {
+		w.caughtUp = make(map[*sqlx.DB]struct{})
+	}
+	w.caughtUp[db] = struct{}{}
+	return db
+}
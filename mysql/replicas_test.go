@@ -0,0 +1,138 @@
+package mysql_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func readSource(t *testing.T, instance *mysql.Instance) string {
+	var source string
+	require.NoError(t, instance.QueryRow("SELECT source").Scan(&source))
+	return source
+}
+
+func TestReplicaPool(t *testing.T) {
+	primary := internal.OpenFakeDB(t, "primary")
+	pool := mysql.NewReplicaPool(0, internal.OpenFakeDB(t, "replica1"), internal.OpenFakeDB(t, "replica2"))
+	t.Cleanup(func() {
+		require.NoError(t, pool.Close())
+	})
+	require.Equal(t, 2, pool.Healthy())
+	instance := internal.NewInstance(t, nil, mysql.WithDB(primary), mysql.WithReplicas(pool))
+
+	t.Run("balances reads", func(t *testing.T) {
+		seen := map[string]int{}
+		for i := 0; i < 4; i++ {
+			seen[readSource(t, instance)]++
+		}
+		require.Equal(t, map[string]int{"replica1": 2, "replica2": 2}, seen)
+		var source string
+		require.NoError(t, instance.QueryFor("SELECT source").For(func(row mysql.Scannable) error {
+			return row.Scan(&source)
+		}))
+		require.Contains(t, []string{"replica1", "replica2"}, source)
+	})
+	t.Run("writes and transactions use the primary", func(t *testing.T) {
+		_, err := instance.Exec("INSERT INTO test_table VALUES ()")
+		require.NoError(t, err)
+		require.Contains(t, internal.FakeDBStatements("primary"), "INSERT INTO test_table VALUES ()")
+		require.NoError(t, instance.BeginTx(nil))
+		require.Equal(t, "primary", readSource(t, instance))
+		require.NoError(t, instance.Rollback())
+	})
+	t.Run("ejects unhealthy replicas", func(t *testing.T) {
+		internal.SetFakeDBDown("replica1", true)
+		pool.CheckHealth()
+		require.Equal(t, 1, pool.Healthy())
+		for i := 0; i < 4; i++ {
+			require.Equal(t, "replica2", readSource(t, instance))
+		}
+		internal.SetFakeDBDown("replica2", true)
+		pool.CheckHealth()
+		require.Equal(t, 0, pool.Healthy())
+		require.Equal(t, "primary", readSource(t, instance))
+		internal.SetFakeDBDown("replica1", false)
+		internal.SetFakeDBDown("replica2", false)
+		pool.CheckHealth()
+		require.Equal(t, 2, pool.Healthy())
+	})
+	t.Run("primary escape hatch", func(t *testing.T) {
+		primary := instance.Primary()
+		require.Equal(t, "primary", readSource(t, primary))
+		require.Equal(t, "primary", readSource(t, primary.SpawnChild()))
+		require.NotEqual(t, "primary", readSource(t, instance), "the pin only applies to the returned instance")
+		require.NotEqual(t, "primary", readSource(t, instance.SpawnChild()))
+	})
+}
+
+func TestReadYourWrites(t *testing.T) {
+	newInstance := func(t *testing.T, consistency mysql.ReadConsistency) (*mysql.Instance, *mysql.ReplicaPool) {
+		pool := mysql.NewReplicaPool(0, internal.OpenFakeDB(t, "replica1"), internal.OpenFakeDB(t, "replica2"))
+		pool.Consistency = consistency
+		t.Cleanup(func() {
+			require.NoError(t, pool.Close())
+		})
+		return internal.NewInstance(t, nil, mysql.WithDB(internal.OpenFakeDB(t, "primary")), mysql.WithReplicas(pool)), pool
+	}
+
+	t.Run("eventual", func(t *testing.T) {
+		instance, _ := newInstance(t, mysql.EventualConsistency)
+		_, err := instance.Exec("INSERT INTO test_table VALUES ()")
+		require.NoError(t, err)
+		require.NotEqual(t, "primary", readSource(t, instance))
+	})
+	t.Run("primary after write", func(t *testing.T) {
+		instance, _ := newInstance(t, mysql.PrimaryAfterWrite)
+		require.NotEqual(t, "primary", readSource(t, instance))
+		require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("INSERT INTO test_table VALUES ()")
+			return err
+		}))
+		require.Equal(t, "primary", readSource(t, instance))
+		require.Equal(t, "primary", readSource(t, instance))
+	})
+	t.Run("writes are shared with the scene", func(t *testing.T) {
+		instance, _ := newInstance(t, mysql.PrimaryAfterWrite)
+		require.NoError(t, instance.SpawnChild().RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("INSERT INTO test_table VALUES ()")
+			return err
+		}))
+		require.Equal(t, "primary", readSource(t, instance))
+		require.Equal(t, "primary", readSource(t, instance.SpawnChild()))
+		require.NotEqual(t, "primary", readSource(t, instance.Isolate()), "isolated instances are not part of the scene")
+	})
+	t.Run("rolled back writes do not count", func(t *testing.T) {
+		instance, _ := newInstance(t, mysql.PrimaryAfterWrite)
+		require.NoError(t, instance.BeginTx(nil))
+		_, err := instance.Exec("INSERT INTO test_table VALUES ()")
+		require.NoError(t, err)
+		require.NoError(t, instance.Rollback())
+		require.NotEqual(t, "primary", readSource(t, instance))
+	})
+	t.Run("wait for gtid", func(t *testing.T) {
+		instance, _ := newInstance(t, mysql.WaitForGTID)
+		internal.SetFakeDBResponse("primary", "SELECT @@GLOBAL.gtid_executed", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+		internal.SetFakeDBResponse("replica1", "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", int64(0))
+		internal.SetFakeDBResponse("replica2", "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", int64(1))
+		_, err := instance.Exec("INSERT INTO test_table VALUES ()")
+		require.NoError(t, err)
+		seen := map[string]int{}
+		for i := 0; i < 4; i++ {
+			seen[readSource(t, instance)]++
+		}
+		// replica2 never catches up, so its share of reads goes to the primary
+		require.Equal(t, map[string]int{"replica1": 2, "primary": 2}, seen)
+		require.Len(t, internal.FakeDBStatements("primary"), 2+2)
+		// replica1 was only waited on once
+		var waits int
+		for _, statement := range internal.FakeDBStatements("replica1") {
+			if statement == "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)" {
+				waits++
+			}
+		}
+		require.Equal(t, 1, waits)
+	})
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how RequireTxRetry replays a transaction that failed on a transient lock error
+type RetryPolicy struct {
+	// Total number of attempts including the first one (defaults to 3)
+	MaxAttempts int
+	// Delay before the first retry, doubled on every following attempt (defaults to 25ms)
+	BaseDelay time.Duration
+	// Upper bound for the delay between two attempts (defaults to 1s)
+	MaxDelay time.Duration
+	// Decides if an error can be retried (defaults to deadlocks and lock wait timeouts)
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy retries deadlocks and lock wait timeouts up to 3 times
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond * 25,
+		MaxDelay:    time.Second,
+		Retryable:   IsRetryableLockError,
+	}
+}
+
+// IsRetryableLockError checks if the error was a deadlock (1213) or a lock wait timeout (1205)
+func IsRetryableLockError(err error) bool {
+	return IsDeadlocked(err) || IsLockWaitTimeout(err)
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Retryable == nil {
+		p.Retryable = def.Retryable
+	}
+	return p
+}
+
+// backoff gets the delay before the next attempt, the upper half of the exponential delay is jittered so that
+// competing transactions do not retry in lockstep.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// RequireTxRetry works like RequireTx, but when the transaction fails with an error the policy considers retryable
+// it is rolled back and f is run again in a new transaction after a backoff.
+// f must be safe to replay. If a transaction is already active, f falls into it and is never retried since only the
+// owner of the outermost transaction can safely replay it. For the same reason f is never retried in a unit of work
+// (see Provider.CommitOnSuccess), the scene owns that transaction.
+func (d *Instance) RequireTxRetry(policy RetryPolicy, f func(db *Instance) error) (err error) {
+	if d.InTx() || d.unitOfWork {
+		return d.RequireTx(f)
+	}
+	policy = policy.withDefaults()
+	for attempt := 1; ; attempt++ {
+		err = d.RequireTx(f)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
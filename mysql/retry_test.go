@@ -0,0 +1,138 @@
+package mysql_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	md "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestIsRetryableLockError(t *testing.T) {
+	require.True(t, mysql.IsRetryableLockError(&md.MySQLError{Number: mysql.ErrCodeDeadlockCode}))
+	require.True(t, mysql.IsRetryableLockError(&md.MySQLError{Number: mysql.ErrCodeLockWaitTimeout}))
+	require.False(t, mysql.IsRetryableLockError(&md.MySQLError{Number: mysql.ErrCodeDuplicateKey}))
+	require.False(t, mysql.IsRetryableLockError(errors.New("test")))
+}
+
+func TestInstance_RequireTxRetry(t *testing.T) {
+	instance := setupInstance(t)
+	policy := mysql.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 5}
+	deadlock := &md.MySQLError{Number: mysql.ErrCodeDeadlockCode, Message: "Deadlock found when trying to get lock"}
+
+	t.Run("retries until success", func(t *testing.T) {
+		var attempts int
+		require.NoError(t, instance.RequireTxRetry(policy, func(db *mysql.Instance) error {
+			attempts++
+			_, err := db.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('retry','retry')")
+			require.NoError(t, err)
+			if attempts < 3 {
+				return deadlock
+			}
+			return nil
+		}))
+		require.Equal(t, 3, attempts)
+		var ct int
+		require.NoError(t, instance.QueryRow("SELECT count(*) FROM test_kvp WHERE `key` = 'retry'").Scan(&ct))
+		require.Equal(t, 1, ct)
+	})
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var attempts int
+		err := instance.RequireTxRetry(policy, func(db *mysql.Instance) error {
+			attempts++
+			return deadlock
+		})
+		require.True(t, mysql.IsDeadlocked(err))
+		require.Equal(t, 3, attempts)
+		require.False(t, instance.InTx())
+	})
+	t.Run("does not retry other errors", func(t *testing.T) {
+		var attempts int
+		err := instance.RequireTxRetry(policy, func(db *mysql.Instance) error {
+			attempts++
+			return errors.New("hi")
+		})
+		require.EqualError(t, err, "hi")
+		require.Equal(t, 1, attempts)
+	})
+	t.Run("does not retry inside an outer transaction", func(t *testing.T) {
+		var attempts int
+		require.NoError(t, instance.BeginTx(nil))
+		err := instance.RequireTxRetry(policy, func(db *mysql.Instance) error {
+			attempts++
+			return deadlock
+		})
+		require.True(t, mysql.IsDeadlocked(err))
+		require.Equal(t, 1, attempts)
+		require.NoError(t, instance.Rollback())
+	})
+}
+
+func TestInstance_RequireTxRetryUnitOfWork(t *testing.T) {
+	instance := internal.NewInstance(t, nil,
+		mysql.WithDB(internal.OpenFakeDB(t, "retry_unit_of_work")), mysql.WithCommitOnSuccess(true),
+	)
+	var attempts int
+	err := instance.RequireTxRetry(mysql.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, func(db *mysql.Instance) error {
+		attempts++
+		return &md.MySQLError{Number: mysql.ErrCodeDeadlockCode, Message: "Deadlock found when trying to get lock"}
+	})
+	require.True(t, mysql.IsDeadlocked(err))
+	require.Equal(t, 1, attempts, "the scene owns the transaction, so it can not be replayed")
+}
+
+func TestInstance_NestedTxDeadlock(t *testing.T) {
+	instance := internal.NewInstance(t, nil, mysql.WithDB(internal.OpenFakeDB(t, "nested_deadlock")))
+	policy := mysql.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 5}
+	// MySQL drops every savepoint when it rolls back a deadlocked transaction
+	internal.SetFakeDBError("nested_deadlock", "ROLLBACK TO SAVEPOINT scene_sp_1", &md.MySQLError{
+		Number: 1305, Message: "SAVEPOINT scene_sp_1 does not exist",
+	})
+	internal.SetFakeDBError("nested_deadlock", "UPDATE locked SET val = 1", &md.MySQLError{
+		Number: mysql.ErrCodeDeadlockCode, Message: "Deadlock found when trying to get lock",
+	})
+	internal.SetFakeDBError("nested_deadlock", "UPDATE missing SET val = 1", &md.MySQLError{
+		Number: mysql.ErrCodeUnknownColumn, Message: "Unknown column 'missing' in 'field list'",
+	})
+
+	t.Run("deadlocks end the transaction", func(t *testing.T) {
+		err := instance.RequireTx(func(db *mysql.Instance) error {
+			return db.RequireTx(func(db *mysql.Instance) error {
+				_, err := db.Exec("UPDATE locked SET val = 1")
+				return err
+			})
+		})
+		require.True(t, mysql.IsDeadlocked(err))
+		require.False(t, instance.InTx())
+		require.NotContains(t, internal.FakeDBStatements("nested_deadlock"), "ROLLBACK TO SAVEPOINT scene_sp_1")
+	})
+	t.Run("deadlocks are retried", func(t *testing.T) {
+		var attempts int
+		require.NoError(t, instance.RequireTxRetry(policy, func(db *mysql.Instance) error {
+			attempts++
+			return db.RequireTx(func(db *mysql.Instance) error {
+				query := "UPDATE locked SET val = 1"
+				if attempts > 1 {
+					query = "UPDATE unlocked SET val = 1"
+				}
+				_, err := db.Exec(query)
+				return err
+			})
+		}))
+		require.Equal(t, 2, attempts)
+	})
+	t.Run("failed savepoint rollbacks keep the cause", func(t *testing.T) {
+		err := instance.RequireTx(func(db *mysql.Instance) error {
+			return db.RequireTx(func(db *mysql.Instance) error {
+				_, err := db.Exec("UPDATE missing SET val = 1")
+				return err
+			})
+		})
+		require.True(t, mysql.IsUnknownColumn(err))
+		require.Contains(t, err.Error(), "rolling back savepoint scene_sp_1 failed: Error 1305")
+		require.False(t, instance.InTx())
+	})
+}
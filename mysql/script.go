@@ -0,0 +1,237 @@
+package mysql
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxScriptErrorStatement is how much of a failed statement is included in a ScriptError's message
+const maxScriptErrorStatement = 120
+
+// ScriptStatement is a single statement of a SQL script
+type ScriptStatement struct {
+	Query string
+	// Line is the line of the script the statement starts on, counting from 1
+	Line int
+}
+
+// ScriptError is returned when a script could not be split or one of its statements failed
+type ScriptError struct {
+	// Line is the line the failed statement starts on, or where the script could not be split
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	if len(e.Statement) == 0 {
+		return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+	}
+	statement := strings.Join(strings.Fields(e.Statement), " ")
+	if len(statement) > maxScriptErrorStatement {
+		statement = statement[:maxScriptErrorStatement] + "..."
+	}
+	return fmt.Sprintf("line %v: %v: %v", e.Line, e.Err, statement)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ScriptOption customizes how ExecScript runs a script
+type ScriptOption func(opts *scriptOptions)
+
+type scriptOptions struct {
+	skip func(statement ScriptStatement) bool
+}
+
+// SkipStatements leaves out the statements skip returns true for, such as the USE statements of a dump that gets
+// loaded into another database
+func SkipStatements(skip func(statement ScriptStatement) bool) ScriptOption {
+	return func(opts *scriptOptions) {
+		opts.skip = skip
+	}
+}
+
+// ExecScript runs every statement of a SQL script on the instance in order, stopping at the first one that fails.
+// The script is split with SplitScript, failures are returned as a *ScriptError.
+// Session settings (such as SET FOREIGN_KEY_CHECKS) only carry between statements if the instance is in a
+// transaction or its pool is limited to a single connection.
+func ExecScript(db *Instance, r io.Reader, opts ...ScriptOption) error {
+	var o scriptOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	statements, err := SplitScript(r)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if o.skip != nil && o.skip(statement) {
+			continue
+		}
+		if _, err = db.Exec(statement.Query); err != nil {
+			return &ScriptError{Line: statement.Line, Statement: statement.Query, Err: err}
+		}
+	}
+	return nil
+}
+
+// SplitScript splits a SQL script into its statements the way the mysql client does.
+// Delimiters inside strings, quoted identifiers and comments are ignored and DELIMITER lines change the delimiter,
+// so triggers and stored procedures can be defined. Comments are removed, apart from executable comments (/*! */)
+// and optimizer hints (/*+ */) which are kept with their statement. Unterminated strings and comments are returned
+// as a *ScriptError.
+func SplitScript(r io.Reader) ([]ScriptStatement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading script")
+	}
+	s := scriptSplitter{script: string(data), delimiter: ";", line: 1}
+	return s.split()
+}
+
+type scriptSplitter struct {
+	script    string
+	delimiter string
+	pos       int
+	line      int
+	buf       strings.Builder
+	start     int
+	out       []ScriptStatement
+}
+
+func (s *scriptSplitter) split() ([]ScriptStatement, error) {
+	for s.pos < len(s.script) {
+		if s.buf.Len() == 0 && s.delimiterCommand() {
+			continue
+		}
+		if strings.HasPrefix(s.script[s.pos:], s.delimiter) {
+			s.pos += len(s.delimiter)
+			s.flush()
+			continue
+		}
+		c := s.script[s.pos]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			if err := s.quoted(); err != nil {
+				return nil, err
+			}
+		case c == '#' || s.lineComment():
+			for s.pos < len(s.script) && s.script[s.pos] != '\n' {
+				s.pos++
+			}
+		case strings.HasPrefix(s.script[s.pos:], "/*"):
+			if err := s.blockComment(); err != nil {
+				return nil, err
+			}
+		default:
+			s.write(s.pos + 1)
+		}
+	}
+	s.flush()
+	return s.out, nil
+}
+
+// delimiterCommand handles a DELIMITER line at the start of a statement
+func (s *scriptSplitter) delimiterCommand() bool {
+	rest := strings.TrimLeft(s.script[s.pos:], " \t\r\n")
+	if len(rest) < len("DELIMITER ") || !strings.EqualFold(rest[:len("DELIMITER ")], "DELIMITER ") {
+		return false
+	}
+	// Only whitespace separates the command from the start of the line
+	skipped := len(s.script) - s.pos - len(rest)
+	s.line += strings.Count(s.script[s.pos:s.pos+skipped], "\n")
+	s.pos += skipped
+	end := strings.IndexByte(s.script[s.pos:], '\n')
+	if end < 0 {
+		end = len(s.script) - s.pos
+	}
+	if fields := strings.Fields(s.script[s.pos+len("DELIMITER ") : s.pos+end]); len(fields) > 0 {
+		s.delimiter = fields[0]
+	}
+	s.pos += end
+	return true
+}
+
+// lineComment checks for a -- comment, which needs whitespace (or the end of the line) after the dashes
+func (s *scriptSplitter) lineComment() bool {
+	if !strings.HasPrefix(s.script[s.pos:], "--") {
+		return false
+	}
+	if s.pos+2 == len(s.script) {
+		return true
+	}
+	switch s.script[s.pos+2] {
+	case ' ', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
+
+// quoted copies a string or quoted identifier, quotes are escaped by doubling them and backslash escapes are
+// honored outside of identifiers
+func (s *scriptSplitter) quoted() error {
+	quote, line := s.script[s.pos], s.line
+	for i := s.pos + 1; i < len(s.script); i++ {
+		switch s.script[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(s.script) && s.script[i+1] == quote {
+				i++
+				continue
+			}
+			s.write(i + 1)
+			return nil
+		}
+	}
+	return &ScriptError{Line: line, Err: errors.Errorf("unterminated %c", quote)}
+}
+
+// blockComment drops a /* */ comment, executable comments and optimizer hints are kept
+func (s *scriptSplitter) blockComment() error {
+	end := strings.Index(s.script[s.pos+2:], "*/")
+	if end < 0 {
+		return &ScriptError{Line: s.line, Err: errors.New("unterminated /* comment")}
+	}
+	end += s.pos + 4
+	if strings.HasPrefix(s.script[s.pos:], "/*!") || strings.HasPrefix(s.script[s.pos:], "/*+") {
+		s.write(end)
+		return nil
+	}
+	s.line += strings.Count(s.script[s.pos:end], "\n")
+	s.pos = end
+	// The comment may have been the only thing separating two tokens
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte(' ')
+	}
+	return nil
+}
+
+// write copies the script up to end into the current statement
+func (s *scriptSplitter) write(end int) {
+	chunk := s.script[s.pos:end]
+	if s.buf.Len() == 0 {
+		trimmed := strings.TrimLeft(chunk, " \t\r\n")
+		s.line += strings.Count(chunk[:len(chunk)-len(trimmed)], "\n")
+		chunk = trimmed
+		s.start = s.line
+	}
+	s.line += strings.Count(chunk, "\n")
+	s.buf.WriteString(chunk)
+	s.pos = end
+}
+
+// flush ends the current statement
+func (s *scriptSplitter) flush() {
+	if query := strings.TrimSpace(s.buf.String()); len(query) > 0 {
+		s.out = append(s.out, ScriptStatement{Query: query, Line: s.start})
+	}
+	s.buf.Reset()
+}
@@ -0,0 +1,110 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestSplitScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []mysql.ScriptStatement
+	}{
+		{
+			name:   "statements",
+			script: "CREATE TABLE a (id INT);\n\nINSERT INTO a VALUES (1);  \nSELECT 1",
+			want: []mysql.ScriptStatement{
+				{Query: "CREATE TABLE a (id INT)", Line: 1},
+				{Query: "INSERT INTO a VALUES (1)", Line: 3},
+				{Query: "SELECT 1", Line: 4},
+			},
+		},
+		{
+			name:   "strings and identifiers",
+			script: "INSERT INTO `semi;colon` VALUES ('a;b', \"c;d\", 'it''s;', 'esc\\';');\nSELECT 1;",
+			want: []mysql.ScriptStatement{
+				{Query: "INSERT INTO `semi;colon` VALUES ('a;b', \"c;d\", 'it''s;', 'esc\\';')", Line: 1},
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "comments",
+			script: "# setup; done here\n-- more; comments\nSELECT 1 -- trailing;\n;\n" +
+				"/* block;\ncomment */ SELECT/* inline */2;\nSELECT 3--4;\nSELECT /*!40101 SQL_NO_CACHE */ /*+ MAX_EXECUTION_TIME(1) */ 5;",
+			want: []mysql.ScriptStatement{
+				{Query: "SELECT 1", Line: 3},
+				{Query: "SELECT 2", Line: 6},
+				{Query: "SELECT 3--4", Line: 7},
+				{Query: "SELECT /*!40101 SQL_NO_CACHE */ /*+ MAX_EXECUTION_TIME(1) */ 5", Line: 8},
+			},
+		},
+		{
+			name: "delimiter",
+			script: "DROP TRIGGER IF EXISTS t;\nDELIMITER $$\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n" +
+				"  SET NEW.id = 1;\n  SET NEW.name = '$$';\nEND$$\n  delimiter ;\nSELECT 1;",
+			want: []mysql.ScriptStatement{
+				{Query: "DROP TRIGGER IF EXISTS t", Line: 1},
+				{Query: "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.id = 1;\n  SET NEW.name = '$$';\nEND", Line: 3},
+				{Query: "SELECT 1", Line: 9},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysql.SplitScript(strings.NewReader(tt.script))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("unterminated", func(t *testing.T) {
+		for script, line := range map[string]int{
+			"SELECT 1;\nSELECT 'open;": 2,
+			"SELECT 1;\n\n/* open":     3,
+			"SELECT `open":             1,
+		} {
+			_, err := mysql.SplitScript(strings.NewReader(script))
+			var scriptErr *mysql.ScriptError
+			require.True(t, errors.As(err, &scriptErr), script)
+			require.Equal(t, line, scriptErr.Line, script)
+		}
+	})
+}
+
+func TestExecScript(t *testing.T) {
+	instance := mysql.NewInstance(context.Background(), internal.OpenFakeDB(t, "script"))
+	internal.SetFakeDBError("script", "INSERT INTO a VALUES (2)", &mysqlDriver.MySQLError{
+		Number: mysql.ErrCodeDuplicateKey, Message: "Duplicate entry '2' for key 'PRIMARY'",
+	})
+	err := mysql.ExecScript(instance, strings.NewReader("INSERT INTO a VALUES (1);\n-- fails\nINSERT INTO a VALUES (2);\nINSERT INTO a VALUES (3);"))
+	var scriptErr *mysql.ScriptError
+	require.True(t, errors.As(err, &scriptErr))
+	require.Equal(t, 3, scriptErr.Line)
+	require.Equal(t, "INSERT INTO a VALUES (2)", scriptErr.Statement)
+	require.True(t, mysql.IsDuplicateKeyError(err))
+	require.Contains(t, err.Error(), "line 3: ")
+	require.Equal(t, []string{"INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"}, internal.FakeDBStatements("script"))
+}
+
+func TestExecScript_SkipStatements(t *testing.T) {
+	instance := mysql.NewInstance(context.Background(), internal.OpenFakeDB(t, "script_skip"))
+	var skipped []int
+	err := mysql.ExecScript(instance, strings.NewReader("USE other;\nINSERT INTO a VALUES (1);\nuse other;\nINSERT INTO a VALUES (2);"),
+		mysql.SkipStatements(func(statement mysql.ScriptStatement) bool {
+			if strings.HasPrefix(strings.ToUpper(statement.Query), "USE ") {
+				skipped = append(skipped, statement.Line)
+				return true
+			}
+			return false
+		}))
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 3}, skipped)
+	require.Equal(t, []string{"INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"}, internal.FakeDBStatements("script_skip"))
+}
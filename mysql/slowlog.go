@@ -0,0 +1,170 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultSlowQueryThreshold is how long an operation may take before it is logged when no threshold is set
+const DefaultSlowQueryThreshold = time.Second
+
+// DefaultExplainInterval is how often a plan is captured for the same normalized statement
+const DefaultExplainInterval = time.Minute
+
+// DefaultExplainTimeout bounds how long capturing a plan may take
+const DefaultExplainTimeout = time.Second * 2
+
+// maxExplainedFingerprints bounds the rate limiter, entries that are past their interval are pruned beyond this
+const maxExplainedFingerprints = 1024
+
+// maxConcurrentExplains bounds how many plans are captured at once, slow queries past it are logged without one
+const maxConcurrentExplains = 2
+
+// SlowQueryOptions configures slow query logging, see WithSlowQueryLog
+type SlowQueryOptions struct {
+	// Threshold is how long an operation may take before it is logged, defaults to DefaultSlowQueryThreshold
+	Threshold time.Duration
+	// Explain captures the plan of slow SELECTs with EXPLAIN FORMAT=JSON on a separate connection in the background
+	// and logs it as its own entry
+	Explain bool
+	// ExplainInterval limits how often a plan is captured for the same normalized statement,
+	// defaults to DefaultExplainInterval
+	ExplainInterval time.Duration
+}
+
+// WithSlowQueryLog logs every operation that takes longer than the threshold through the provider's logger, along
+// with where it was called from, its redacted arguments and whether it ran in a transaction.
+// Loggers that implement Warnf(format string, v ...interface{}) are logged to at a warning level.
+func WithSlowQueryLog(opts SlowQueryOptions) ProviderOption {
+	return func(provider *Provider) {
+		provider.slowQueries = &opts
+	}
+}
+
+// slowQueryLog logs slow operations, it is shared by every instance of a provider
+type slowQueryLog struct {
+	SlowQueryOptions
+	logf      func(format string, v ...interface{})
+	name      string
+	db        *sqlx.DB
+	mu        sync.Mutex
+	explained map[string]time.Time
+	// explaining holds a slot for every plan being captured
+	explaining chan struct{}
+}
+
+func newSlowQueryLog(opts SlowQueryOptions, log logger, name string, db *sqlx.DB) *slowQueryLog {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultSlowQueryThreshold
+	}
+	if opts.ExplainInterval <= 0 {
+		opts.ExplainInterval = DefaultExplainInterval
+	}
+	return &slowQueryLog{
+		SlowQueryOptions: opts,
+		logf:             warnf(log),
+		name:             name,
+		db:               db,
+		explained:        make(map[string]time.Time),
+		explaining:       make(chan struct{}, maxConcurrentExplains),
+	}
+}
+
+func (l *slowQueryLog) interceptor() Interceptor {
+	return func(ctx context.Context, stmt *Statement, next Invoker) error {
+		started := time.Now()
+		err := next(ctx, stmt)
+		if took := time.Since(started); took >= l.Threshold {
+			l.log(stmt, took)
+		}
+		return err
+	}
+}
+
+func (l *slowQueryLog) log(stmt *Statement, took time.Duration) {
+	statement := string(stmt.Op)
+	if len(stmt.Query) > 0 {
+		statement = NormalizeQuery(stmt.Query)
+	}
+	caller := externalCaller()
+	l.logf("Slow query%v took %v on %v (in transaction: %v): %v args: %v",
+		logName(l.name), took, caller, stmt.InTx, statement, redactArgs(stmt.Args))
+	if !l.Explain || !strings.HasPrefix(strings.ToUpper(statement), "SELECT") {
+		return
+	}
+	select {
+	case l.explaining <- struct{}{}:
+	default:
+		// Enough plans are being captured already, the database is likely struggling as it is
+		return
+	}
+	if !l.shouldExplain(statement) {
+		<-l.explaining
+		return
+	}
+	// The statement may be reused by the caller once this returns
+	query, args := stmt.Query, append([]any(nil), stmt.Args...)
+	go func() {
+		defer func() { <-l.explaining }()
+		plan, err := l.explain(query, args)
+		if err != nil {
+			l.logf("Plan of slow query%v on %v could not be captured, %v: %v", logName(l.name), caller, err, statement)
+			return
+		}
+		l.logf("Plan of slow query%v on %v: %v plan: %v", logName(l.name), caller, statement, plan)
+	}()
+}
+
+// shouldExplain rate limits plans per normalized statement
+func (l *slowQueryLog) shouldExplain(fingerprint string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if last, found := l.explained[fingerprint]; found && now.Sub(last) < l.ExplainInterval {
+		return false
+	}
+	if len(l.explained) >= maxExplainedFingerprints {
+		for key, last := range l.explained {
+			if now.Sub(last) >= l.ExplainInterval {
+				delete(l.explained, key)
+			}
+		}
+		if len(l.explained) >= maxExplainedFingerprints {
+			return false
+		}
+	}
+	l.explained[fingerprint] = now
+	return true
+}
+
+// explain captures the plan using the primary's pool, so it never runs on the connection the statement held
+func (l *slowQueryLog) explain(query string, args []any) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultExplainTimeout)
+	defer cancel()
+	var plan string
+	err := l.db.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+query, args...).Scan(&plan)
+	return plan, respErrorHandler(err)
+}
+
+// redactArgs describes arguments by their type (and length) without exposing their values
+func redactArgs(args []any) string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case nil:
+			out = append(out, "NULL")
+		case string:
+			out = append(out, fmt.Sprintf("string(%v)", len(val)))
+		case []byte:
+			out = append(out, fmt.Sprintf("[]byte(%v)", len(val)))
+		default:
+			out = append(out, fmt.Sprintf("%T", arg))
+		}
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
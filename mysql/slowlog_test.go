@@ -0,0 +1,113 @@
+package mysql_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	errors   []string
+	warnings []string
+}
+
+func (l *recordingLogger) Errorf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Warnf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.warnings
+	l.warnings = nil
+	return out
+}
+
+func TestProvider_SlowQueryLog(t *testing.T) {
+	logger := &recordingLogger{}
+	db := internal.OpenFakeDB(t, "slow")
+	ctx := internal.NewScene(t, logger,
+		mysql.WithDB(db),
+		mysql.WithName("reports"),
+		mysql.WithSlowQueryLog(mysql.SlowQueryOptions{Threshold: time.Millisecond * 20, Explain: true}),
+		mysql.WithInterceptors(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			if strings.Contains(stmt.Query, "slow") {
+				time.Sleep(time.Millisecond * 25)
+			}
+			return next(ctx, stmt)
+		}),
+	)
+	instance := mysql.GetManagedDatabaseInstanceNamed(ctx, "reports")
+	const query = "SELECT source FROM slow_table WHERE id = ? AND name = 'bob'"
+	internal.SetFakeDBResponse("slow", "EXPLAIN FORMAT=JSON "+query, `{"query_block": {"select_id": 1}}`)
+
+	t.Run("fast", func(t *testing.T) {
+		require.NoError(t, instance.QueryRow("SELECT source").Err())
+		require.Empty(t, logger.take())
+	})
+	t.Run("slow", func(t *testing.T) {
+		require.NoError(t, instance.QueryRow(query, "secret").Err())
+		logs := logger.take()
+		require.Len(t, logs, 1)
+		require.Contains(t, logs[0], "Slow query [reports] took")
+		require.Contains(t, logs[0], "slowlog_test.go:")
+		require.Contains(t, logs[0], "(in transaction: false): SELECT source FROM slow_table WHERE id = ? AND name = ?")
+		require.Contains(t, logs[0], "args: [string(6)]")
+		require.NotContains(t, logs[0], "secret")
+		require.NotContains(t, logs[0], "bob")
+		require.NotContains(t, logs[0], "plan:", "the plan is captured in the background")
+		// The plan is logged on its own once it was captured
+		require.Eventually(t, func() bool {
+			logs = append(logs, logger.take()...)
+			return len(logs) == 2
+		}, time.Second, time.Millisecond*5)
+		require.Contains(t, logs[1], "Plan of slow query [reports] on ")
+		require.Contains(t, logs[1], "slowlog_test.go:")
+		require.Contains(t, logs[1], `SELECT source FROM slow_table WHERE id = ? AND name = ? plan: {"query_block": {"select_id": 1}}`)
+		require.Contains(t, internal.FakeDBStatements("slow"), "EXPLAIN FORMAT=JSON "+query)
+	})
+	t.Run("plans are rate limited", func(t *testing.T) {
+		require.NoError(t, instance.QueryRow(query, "secret").Err())
+		time.Sleep(time.Millisecond * 20)
+		logs := logger.take()
+		require.Len(t, logs, 1)
+		require.NotContains(t, logs[0], "Plan of")
+	})
+	t.Run("writes and transactions", func(t *testing.T) {
+		require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+			_, err := db.Exec("UPDATE slow_table SET val = ?", 12)
+			return err
+		}))
+		logs := logger.take()
+		require.Len(t, logs, 1)
+		require.Contains(t, logs[0], "(in transaction: true): UPDATE slow_table SET val = ? args: [int]")
+		require.NotContains(t, logs[0], "plan:")
+		require.Empty(t, logger.errors)
+	})
+
+	t.Run("default threshold", func(t *testing.T) {
+		logger := &recordingLogger{}
+		instance := internal.NewInstance(t, logger,
+			mysql.WithDB(internal.OpenFakeDB(t, "slow-default")),
+			mysql.WithSlowQueryLog(mysql.SlowQueryOptions{}),
+		)
+		require.NoError(t, instance.QueryRow("SELECT source").Err())
+		require.Empty(t, logger.take(), "a zero threshold does not log every operation")
+	})
+}
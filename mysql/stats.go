@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"sync"
+	"time"
+)
+
+// QueryStats summarizes the work an instance (and the children spawned from it) sent to the database
+type QueryStats struct {
+	// Queries is the number of statements that ran, transaction calls and savepoints are not included
+	Queries int
+	// DBTime is the total time spent waiting on the database, transaction calls included
+	DBTime time.Duration
+	// RowsScanned is the number of rows read from results
+	RowsScanned int64
+	// Statements counts how many times each normalized statement ran, see NormalizeQuery.
+	// Only the first 1000 distinct statements are counted, the rest are only included in Queries.
+	Statements map[string]int
+}
+
+// maxStatements bounds how many distinct statements are counted for an instance, a long-lived scene that builds its
+// statements dynamically would otherwise grow the stats without bound
+const maxStatements = 1000
+
+// Repeated gets the normalized statements that ran more than threshold times, which usually points to an N+1
+func (s QueryStats) Repeated(threshold int) map[string]int {
+	out := make(map[string]int)
+	for statement, ct := range s.Statements {
+		if ct > threshold {
+			out[statement] = ct
+		}
+	}
+	return out
+}
+
+// WithNPlusOneWarning logs a warning when a scene completes if the same normalized statement ran more than
+// threshold times in it
+func WithNPlusOneWarning(threshold int) ProviderOption {
+	return func(provider *Provider) {
+		provider.nPlusOneThreshold = threshold
+	}
+}
+
+// queryStats is shared by an instance and its children
+type queryStats struct {
+	mu          sync.Mutex
+	queries     int
+	statements  map[string]int
+	dbTime      time.Duration
+	rowsScanned int64
+}
+
+func (s *queryStats) record(stmt *Statement, took time.Duration) {
+	if s == nil {
+		return
+	}
+	var statement string
+	var counted bool
+	switch stmt.Op {
+	case OpQuery, OpQueryx, OpQueryRow, OpQueryRowx, OpExec:
+		// Literals are normalized away so statements that only differ by them are counted together
+		statement, counted = NormalizeQuery(stmt.Query), true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dbTime += took
+	if !counted {
+		return
+	}
+	s.queries++
+	if s.statements == nil {
+		s.statements = make(map[string]int)
+	}
+	if _, found := s.statements[statement]; found || len(s.statements) < maxStatements {
+		s.statements[statement]++
+	}
+}
+
+func (s *queryStats) scanned(rows int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.rowsScanned += rows
+	s.mu.Unlock()
+}
+
+func (s *queryStats) snapshot() QueryStats {
+	out := QueryStats{Statements: make(map[string]int)}
+	if s == nil {
+		return out
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out.DBTime = s.dbTime
+	out.RowsScanned = s.rowsScanned
+	out.Queries = s.queries
+	for statement, ct := range s.statements {
+		out.Statements[statement] = ct
+	}
+	return out
+}
+
+// Stats gets the statistics of every statement this instance (and the children spawned from it) has run
+func (d *Instance) Stats() QueryStats {
+	return d.stats.snapshot()
+}
@@ -0,0 +1,66 @@
+package mysql_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestInstance_Stats(t *testing.T) {
+	logger := &recordingLogger{}
+	factory, _ := internal.NewSceneFactory(t, logger, mysql.WithDB(internal.OpenFakeDB(t, "stats")), mysql.WithNPlusOneWarning(3))
+	ctx, err := factory.NewCtx()
+	require.NoError(t, err)
+	instance := mysql.GetManagedDatabaseInstance(ctx)
+
+	var source string
+	for i := 0; i < 4; i++ {
+		require.NoError(t, instance.QueryRow("SELECT source FROM users WHERE id = "+strconv.Itoa(i)).Scan(&source))
+	}
+	require.NoError(t, instance.QueryFor("SELECT source FROM orders").For(func(row mysql.Scannable) error {
+		return row.Scan(&source)
+	}))
+	require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+		_, err := db.SpawnChild().Exec("UPDATE orders SET total = ?", 1)
+		return err
+	}))
+	_, err = instance.Isolate().Exec("DELETE FROM orders")
+	require.NoError(t, err)
+
+	stats := instance.Stats()
+	require.Equal(t, 6, stats.Queries)
+	require.Equal(t, int64(5), stats.RowsScanned)
+	require.Greater(t, stats.DBTime, time.Duration(0))
+	require.Equal(t, map[string]int{
+		"SELECT source FROM users WHERE id = ?": 4,
+		"SELECT source FROM orders":             1,
+		"UPDATE orders SET total = ?":           1,
+	}, stats.Statements)
+	require.Equal(t, map[string]int{"SELECT source FROM users WHERE id = ?": 4}, stats.Repeated(3))
+
+	require.Empty(t, logger.take())
+	ctx.Complete()
+	require.Equal(t, []string{
+		"Statement ran 4 times in one scene, this is likely an N+1: SELECT source FROM users WHERE id = ?",
+	}, logger.take())
+
+	t.Run("distinct statements are capped", func(t *testing.T) {
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		defer ctx.Complete()
+		instance := mysql.GetManagedDatabaseInstance(ctx)
+		for i := 0; i < 1010; i++ {
+			// Every statement has its own shape, literals alone would be normalized into one
+			_, err := instance.Exec("UPDATE orders_" + strconv.Itoa(i) + "x SET total = " + strconv.Itoa(i))
+			require.NoError(t, err)
+		}
+		stats := instance.Stats()
+		require.Equal(t, 1010, stats.Queries)
+		require.Len(t, stats.Statements, 1000)
+		require.Equal(t, 1, stats.Statements["UPDATE orders_0x SET total = ?"])
+	})
+}
@@ -0,0 +1,161 @@
+package mysql
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var ErrTestTxInterleaved = errors.New("another instance holds a newer savepoint of the test transaction")
+
+// testTx is a transaction owned by a test that every instance of a provider runs inside, see WithTestTx
+type testTx struct {
+	tx *sqlx.Tx
+	// Instances share the connection, so every savepoint needs a name no other instance is using
+	seq atomic.Int64
+	mu  sync.Mutex
+	// savepoints is the stack of savepoints instances currently hold, from the oldest to the newest
+	savepoints []string
+}
+
+// push records a savepoint that was just set
+func (t *testTx) push(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.savepoints = append(t.savepoints, name)
+}
+
+// newest checks that no other savepoint was set after name, rolling back to or releasing a savepoint takes the newer
+// ones with it, which would discard or commit the work of whichever instance set them
+func (t *testTx) newest(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.savepoints) == 0 || t.savepoints[len(t.savepoints)-1] != name {
+		return errors.Wrapf(ErrTestTxInterleaved, "ending savepoint %v", name)
+	}
+	return nil
+}
+
+// end forgets name once it is released or rolled back to, see newest. A savepoint being abandoned is forgotten even if
+// it is not the newest since its instance no longer uses it.
+func (t *testTx) end(name string, abandon bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last := len(t.savepoints) - 1
+	// Savepoints of a nested RequireTx the instance did not get to end go along with its own
+	for last >= 0 && strings.HasPrefix(t.savepoints[last], name+"_sp_") {
+		last--
+	}
+	if last >= 0 && t.savepoints[last] == name {
+		t.savepoints = t.savepoints[:last]
+		return nil
+	}
+	if abandon {
+		for idx := range t.savepoints {
+			if t.savepoints[idx] == name {
+				t.savepoints = append(t.savepoints[:idx], t.savepoints[idx+1:]...)
+				break
+			}
+		}
+	}
+	return errors.Wrapf(ErrTestTxInterleaved, "ending savepoint %v", name)
+}
+
+// WithTestTx runs every statement of the provider's instances inside tx, which belongs to the caller and is never
+// committed or rolled back by an instance. Transactions started through an instance become savepoints of tx, so code
+// under test behaves as it would on its own while everything it wrote can be rolled back once the test ends.
+// The instances share the connection of tx, rows must be closed before another instance runs a statement.
+// Savepoints only nest, so transactions of instances (concurrent scenes for example) must not overlap, an instance
+// ending its transaction while another one started after it is still open fails with ErrTestTxInterleaved rather
+// than discarding or releasing the other instance's work along with its own.
+// This is meant for tests, see mysqltest.DB.Isolated.
+func WithTestTx(tx *sqlx.Tx) ProviderOption {
+	return func(provider *Provider) {
+		provider.testTx = &testTx{tx: tx}
+	}
+}
+
+// activeTx gets the transaction statements run in, nil when they run on a pool
+func (d *Instance) activeTx() *sqlx.Tx {
+	if d.tx == nil && d.testTx != nil {
+		return d.testTx.tx
+	}
+	return d.tx
+}
+
+// beginTestTx stands a savepoint of the test transaction in for a transaction
+func (d *Instance) beginTestTx() error {
+	started := time.Now()
+	name := "scene_test_" + strconv.FormatInt(d.testTx.seq.Add(1), 10)
+	err := d.run(&Statement{Op: OpBeginTx, Query: "SAVEPOINT " + name}, func(ctx context.Context, stmt *Statement) error {
+		_, err := d.testTx.tx.ExecContext(ctx, stmt.Query)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	d.testTx.push(name)
+	d.tx = d.testTx.tx
+	d.testSavepoint = name
+	d.inFlight.inTx.Store(true)
+	d.txStarted = started
+	return nil
+}
+
+// endTx commits or rolls back the active transaction, when running in a test transaction the savepoint standing in
+// for it is released or rolled back to instead. A rollback always happens, even if an interceptor failed it without
+// running it, since the transaction would otherwise hold on to its connection.
+func (d *Instance) endTx(op Op) error {
+	var ran bool
+	var err error
+	if d.testTx == nil {
+		err = d.run(&Statement{Op: op}, func(ctx context.Context, stmt *Statement) error {
+			ran = true
+			if op == OpCommit {
+				return d.tx.Commit()
+			}
+			return d.tx.Rollback()
+		})
+		if !ran && op == OpRollback {
+			_ = d.tx.Rollback()
+		}
+		return err
+	}
+	statement := "ROLLBACK TO SAVEPOINT "
+	if op == OpCommit {
+		statement = "RELEASE SAVEPOINT "
+	}
+	if err = d.testTx.end(d.testSavepoint, op == OpRollback); err != nil {
+		return err
+	}
+	err = d.run(&Statement{Op: op, Query: statement + d.testSavepoint}, func(ctx context.Context, stmt *Statement) error {
+		ran = true
+		_, err := d.tx.ExecContext(ctx, stmt.Query)
+		return err
+	})
+	if !ran && op == OpRollback {
+		_, _ = d.tx.ExecContext(d.ctx, statement+d.testSavepoint)
+	}
+	return err
+}
+
+// trackSavepoint records a savepoint set by a nested RequireTx in the test transaction
+func (d *Instance) trackSavepoint(name string) {
+	if d.testTx != nil {
+		d.testTx.push(name)
+	}
+}
+
+// endSavepoint checks a savepoint set by a nested RequireTx can be released or rolled back to, see testTx.end
+func (d *Instance) endSavepoint(name string, abandon bool) error {
+	if d.testTx == nil {
+		return nil
+	}
+	return d.testTx.end(name, abandon)
+}
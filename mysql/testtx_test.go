@@ -0,0 +1,131 @@
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+func TestProvider_TestTx(t *testing.T) {
+	db := internal.OpenFakeDB(t, "testtx")
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	factory, _ := internal.NewSceneFactory(t, nil, mysql.WithDB(db), mysql.WithTestTx(tx))
+	// Statements recorded since the last call
+	var seen int
+	statements := func() []string {
+		all := internal.FakeDBStatements("testtx")
+		out := all[seen:]
+		seen = len(all)
+		return out
+	}
+	require.Equal(t, []string{"BEGIN"}, statements())
+
+	t.Run("outside a transaction", func(t *testing.T) {
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		instance := mysql.GetManagedDatabaseInstance(ctx)
+		_, err = instance.Exec("DELETE FROM test_kvp")
+		require.NoError(t, err)
+		require.False(t, instance.InTx())
+		require.NoError(t, instance.SpawnChild().QueryRow("SELECT source").Err())
+		ctx.Complete()
+		require.Equal(t, []string{"DELETE FROM test_kvp", "SELECT source"}, statements())
+	})
+	t.Run("transactions become savepoints", func(t *testing.T) {
+		ctx, err := factory.NewCtx()
+		require.NoError(t, err)
+		instance := mysql.GetManagedDatabaseInstance(ctx)
+		var committed bool
+		require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+			require.True(t, db.InTx())
+			db.AfterCommit(func() {
+				committed = true
+			})
+			require.Error(t, db.RequireTx(func(db *mysql.Instance) error {
+				return errors.New("nested failure")
+			}))
+			require.NoError(t, db.PartialCommit())
+			_, err := db.Exec("DELETE FROM test_kvp")
+			return err
+		}))
+		require.True(t, committed)
+		require.False(t, instance.InTx())
+		require.Equal(t, []string{
+			"SAVEPOINT scene_test_1",
+			"SAVEPOINT scene_test_1_sp_1",
+			"ROLLBACK TO SAVEPOINT scene_test_1_sp_1",
+			"SAVEPOINT scene_test_1",
+			"DELETE FROM test_kvp",
+			"RELEASE SAVEPOINT scene_test_1",
+		}, statements())
+
+		require.NoError(t, instance.BeginTx(nil))
+		require.NoError(t, instance.Rollback())
+		require.ErrorIs(t, instance.Commit(), mysql.ErrNoActiveTransaction)
+		require.Equal(t, []string{"SAVEPOINT scene_test_2", "ROLLBACK TO SAVEPOINT scene_test_2"}, statements())
+
+		// Closing rolls back to the savepoint, the test transaction stays open
+		require.NoError(t, instance.BeginTx(nil))
+		ctx.Complete()
+		require.Equal(t, []string{"SAVEPOINT scene_test_3", "ROLLBACK TO SAVEPOINT scene_test_3"}, statements())
+	})
+	t.Run("unit of work", func(t *testing.T) {
+		ctx := internal.NewScene(t, nil, mysql.WithDB(db), mysql.WithTestTx(tx), mysql.WithCommitOnSuccess(true))
+		_, err := mysql.GetManagedDatabaseInstance(ctx).Exec("DELETE FROM test_kvp")
+		require.NoError(t, err)
+		ctx.Complete()
+		require.Equal(t, []string{"SAVEPOINT scene_test_1", "DELETE FROM test_kvp", "RELEASE SAVEPOINT scene_test_1"}, statements())
+	})
+	t.Run("interleaved transactions", func(t *testing.T) {
+		first, err := factory.NewCtx()
+		require.NoError(t, err)
+		defer first.Complete()
+		second, err := factory.NewCtx()
+		require.NoError(t, err)
+		defer second.Complete()
+		a, b := mysql.GetManagedDatabaseInstance(first), mysql.GetManagedDatabaseInstance(second)
+		var inTx []bool
+		a.Use(func(ctx context.Context, stmt *mysql.Statement, next mysql.Invoker) error {
+			inTx = append(inTx, stmt.InTx)
+			return next(ctx, stmt)
+		})
+		_, err = a.Exec("DELETE FROM test_kvp")
+		require.NoError(t, err)
+		require.Equal(t, []bool{true}, inTx, "statements outside a transaction still run in the test transaction")
+
+		require.NoError(t, a.BeginTx(nil))
+		require.NoError(t, b.BeginTx(nil))
+		require.Equal(t, []string{"DELETE FROM test_kvp", "SAVEPOINT scene_test_4", "SAVEPOINT scene_test_5"}, statements())
+		// Releasing the older savepoint would release the newer one along with it
+		require.ErrorIs(t, a.Commit(), mysql.ErrTestTxInterleaved)
+		require.ErrorIs(t, a.PartialCommit(), mysql.ErrTestTxInterleaved)
+		require.Empty(t, statements())
+		require.NoError(t, b.Commit())
+		require.NoError(t, a.Commit())
+		require.Equal(t, []string{"RELEASE SAVEPOINT scene_test_5", "RELEASE SAVEPOINT scene_test_4"}, statements())
+
+		// Rolling back abandons the savepoint either way
+		require.NoError(t, a.BeginTx(nil))
+		require.NoError(t, b.BeginTx(nil))
+		require.ErrorIs(t, a.Rollback(), mysql.ErrTestTxInterleaved)
+		require.False(t, a.InTx())
+		require.NoError(t, b.RequireTx(func(db *mysql.Instance) error {
+			return db.Rollback()
+		}))
+		require.Equal(t, []string{
+			"SAVEPOINT scene_test_6", "SAVEPOINT scene_test_7",
+			"SAVEPOINT scene_test_7_sp_1", "ROLLBACK TO SAVEPOINT scene_test_7",
+		}, statements())
+		require.NoError(t, a.RequireTx(func(db *mysql.Instance) error {
+			return nil
+		}))
+		require.Equal(t, []string{"SAVEPOINT scene_test_8", "RELEASE SAVEPOINT scene_test_8"}, statements())
+	})
+	require.NoError(t, tx.Rollback())
+	require.Equal(t, []string{"ROLLBACK"}, statements())
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name spans are created under
+const TracerName = "github.com/weisbartb/scene-db/mysql"
+
+// Span attributes beyond db.system and db.statement, which follow the OTel database conventions
+const (
+	AttrInTransaction = attribute.Key("db.mysql.in_transaction")
+	AttrRowsAffected  = attribute.Key("db.mysql.rows_affected")
+	AttrErrorCode     = attribute.Key("db.mysql.error_code")
+)
+
+// WithTracing creates a span for every statement and transaction call made through the provider's instances,
+// the span is parented to the span in the scene context. A nil provider uses the global tracer provider.
+func WithTracing(tp trace.TracerProvider) ProviderOption {
+	return func(provider *Provider) {
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+		provider.tracer = tp.Tracer(TracerName)
+	}
+}
+
+// tracingInterceptor creates a span for every operation, the statement is normalized before it is recorded so
+// literals never end up in a trace
+func tracingInterceptor(tracer trace.Tracer) Interceptor {
+	return func(ctx context.Context, stmt *Statement, next Invoker) error {
+		attrs := []attribute.KeyValue{
+			attribute.String("db.system", "mysql"),
+			AttrInTransaction.Bool(stmt.InTx),
+		}
+		if len(stmt.Query) > 0 {
+			attrs = append(attrs, attribute.String("db.statement", NormalizeQuery(stmt.Query)))
+		}
+		ctx, span := tracer.Start(ctx, "mysql."+string(stmt.Op), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+		err := next(ctx, stmt)
+		if stmt.RowsAffected >= 0 {
+			span.SetAttributes(AttrRowsAffected.Int64(stmt.RowsAffected))
+		}
+		if err != nil {
+			if code := GetErrorCode(err); code != 0 {
+				span.SetAttributes(AttrErrorCode.Int(int(code)))
+			}
+			description := errorDescription(err)
+			span.AddEvent("exception", trace.WithAttributes(
+				attribute.String("exception.type", fmt.Sprintf("%T", err)),
+				attribute.String("exception.message", description),
+			))
+			span.SetStatus(codes.Error, description)
+		}
+		return err
+	}
+}
+
+// errorDescription describes err for a trace. Server messages can contain row values (such as the entry of a
+// duplicate key), so MySQL errors are described by their kind and code instead.
+func errorDescription(err error) string {
+	if code := GetErrorCode(err); code != 0 {
+		return fmt.Sprintf("%v (%v)", ClassifyError(err), code)
+	}
+	return err.Error()
+}
@@ -0,0 +1,91 @@
+package mysql_test
+
+import (
+	"context"
+	"testing"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	out := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		out[attr.Key] = attr.Value
+	}
+	return out
+}
+
+func TestProvider_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	factory, _ := internal.NewSceneFactory(t, nil, mysql.WithDB(internal.OpenFakeDB(t, "traced")), mysql.WithTracing(tp))
+	requestCtx, request := tp.Tracer("test").Start(context.Background(), "request")
+	defer request.End()
+	ctx, err := factory.Wrap(requestCtx)
+	require.NoError(t, err)
+	t.Cleanup(ctx.Complete)
+	instance := mysql.GetManagedDatabaseInstance(ctx)
+
+	t.Run("statements", func(t *testing.T) {
+		exporter.Reset()
+		_, err := instance.Exec("UPDATE test_kvp SET val = 'secret' WHERE `key` = ?", "k")
+		require.NoError(t, err)
+		spans := exporter.GetSpans().Snapshots()
+		require.Len(t, spans, 1)
+		require.Equal(t, "mysql.Exec", spans[0].Name())
+		require.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+		require.Equal(t, request.SpanContext().SpanID(), spans[0].Parent().SpanID())
+		attrs := spanAttrs(spans[0])
+		require.Equal(t, "mysql", attrs["db.system"].AsString())
+		require.Equal(t, "UPDATE test_kvp SET val = ? WHERE `key` = ?", attrs["db.statement"].AsString())
+		require.Equal(t, int64(1), attrs[mysql.AttrRowsAffected].AsInt64())
+		require.False(t, attrs[mysql.AttrInTransaction].AsBool())
+	})
+	t.Run("transactions", func(t *testing.T) {
+		exporter.Reset()
+		require.NoError(t, instance.RequireTx(func(db *mysql.Instance) error {
+			var source string
+			return db.QueryRowx("SELECT source").Scan(&source)
+		}))
+		var names []string
+		for _, span := range exporter.GetSpans().Snapshots() {
+			names = append(names, span.Name())
+			if span.Name() == "mysql.QueryRowx" {
+				require.True(t, spanAttrs(span)[mysql.AttrInTransaction].AsBool())
+			}
+		}
+		require.Equal(t, []string{"mysql.BeginTx", "mysql.QueryRowx", "mysql.Commit"}, names)
+	})
+	t.Run("errors", func(t *testing.T) {
+		exporter.Reset()
+		query := "INSERT INTO test_kvp VALUES (1)"
+		internal.SetFakeDBError("traced", query, &mysqlDriver.MySQLError{
+			Number: mysql.ErrCodeDuplicateKey, Message: "Duplicate entry '1' for key 'PRIMARY'",
+		})
+		_, err := instance.Exec(query)
+		require.True(t, mysql.IsDuplicateKeyError(err))
+		spans := exporter.GetSpans().Snapshots()
+		require.Len(t, spans, 1)
+		require.Equal(t, codes.Error, spans[0].Status().Code)
+		require.Equal(t, "duplicate_key (1062)", spans[0].Status().Description, "the server message can contain row values")
+		require.Equal(t, int64(mysql.ErrCodeDuplicateKey), spanAttrs(spans[0])[mysql.AttrErrorCode].AsInt64())
+		require.Len(t, spans[0].Events(), 1)
+		for _, attr := range spans[0].Events()[0].Attributes {
+			require.NotContains(t, attr.Value.Emit(), "Duplicate entry")
+		}
+	})
+	t.Run("opt-in", func(t *testing.T) {
+		exporter.Reset()
+		untraced := mysql.NewInstance(requestCtx, instance.Raw())
+		require.NoError(t, untraced.QueryRow("SELECT source").Err())
+		require.Empty(t, exporter.GetSpans())
+	})
+}
@@ -0,0 +1,125 @@
+package mysql
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// IterT is a typed version of Iter that scans every row into a T before handing it off
+type IterT[T any] struct {
+	rows *Rowsx
+	err  error
+}
+
+// For scans each row into a T and invokes fn with it.
+// Errors from the underlying connection are returned prior to the first invocation of fn.
+func (i *IterT[T]) For(fn func(row T) error) (err error) {
+	if i.err != nil {
+		return i.err
+	}
+	defer i.rows.Close()
+	scan := rowScanner[T]()
+	for i.rows.Next() {
+		var row T
+		if err = scan(i.rows.Rows, &row); err != nil {
+			return i.rows.errs.wrap(err)
+		}
+		if err = fn(row); err != nil {
+			return
+		}
+	}
+	return i.rows.Err()
+}
+
+// QueryForT runs a query and returns an iterable that scans every row into a T
+// err := mysql.QueryForT[User](db, ...).For(func(user User) error { ... })
+func QueryForT[T any](db *Instance, query string, args ...any) *IterT[T] {
+	iter := &IterT[T]{}
+	iter.rows, iter.err = db.queryx(1, query, args...)
+	return iter
+}
+
+// Get runs a query and scans the first row into a T.
+// If the query has no results sql.ErrNoRows is returned.
+func Get[T any](db *Instance, query string, args ...any) (out T, err error) {
+	return get[T](db, 1, query, args...)
+}
+
+// get runs Get, skip is the number of frames between get and the caller the open rows are attributed to
+func get[T any](db *Instance, skip int, query string, args ...any) (out T, err error) {
+	rows, err := db.queryx(skip+1, query, args...)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return out, err
+		}
+		return out, sql.ErrNoRows
+	}
+	err = rowScanner[T]()(rows.Rows, &out)
+	return out, rows.errs.wrap(err)
+}
+
+// Select runs a query and scans every row into a slice of T
+func Select[T any](db *Instance, query string, args ...any) (out []T, err error) {
+	rows, err := db.queryx(1, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scan := rowScanner[T]()
+	for rows.Next() {
+		var row T
+		if err = scan(rows.Rows, &row); err != nil {
+			return nil, rows.errs.wrap(err)
+		}
+		out = append(out, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rowScanner picks how a T gets scanned, structs are mapped by column name while anything else
+// (scalars, sql.Scanner implementations and structs without exported fields like time.Time) are scanned directly.
+func rowScanner[T any]() func(rows *sqlx.Rows, dest *T) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer && !isScannable(t.Elem()) {
+		// Pointers to structs get a fresh struct to be mapped into
+		return func(rows *sqlx.Rows, dest *T) error {
+			row := reflect.New(t.Elem())
+			if err := rows.StructScan(row.Interface()); err != nil {
+				return err
+			}
+			reflect.ValueOf(dest).Elem().Set(row)
+			return nil
+		}
+	}
+	if isScannable(t) {
+		return func(rows *sqlx.Rows, dest *T) error {
+			return rows.Scan(dest)
+		}
+	}
+	return func(rows *sqlx.Rows, dest *T) error {
+		return rows.StructScan(dest)
+	}
+}
+
+func isScannable(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(scannerType) || t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
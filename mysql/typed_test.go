@@ -0,0 +1,89 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weisbartb/scene-db/mysql"
+	"github.com/weisbartb/scene-db/mysql/internal"
+)
+
+type testKVP struct {
+	Key string `db:"key"`
+	Val string `db:"val"`
+}
+
+func TestGet(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2')")
+	require.NoError(t, err)
+	kvp, err := mysql.Get[testKVP](instance, "SELECT `key`,`val` FROM test_kvp WHERE `key` = ?", "test2")
+	require.NoError(t, err)
+	require.Equal(t, testKVP{Key: "test2", Val: "test2"}, kvp)
+	ct, err := mysql.Get[int](instance, "SELECT count(*) FROM test_kvp")
+	require.NoError(t, err)
+	require.Equal(t, 2, ct)
+	now, err := mysql.Get[time.Time](instance, "SELECT NOW()")
+	require.NoError(t, err)
+	require.False(t, now.IsZero())
+	_, err = mysql.Get[testKVP](instance, "SELECT `key`,`val` FROM test_kvp WHERE `key` = ?", "missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = mysql.Get[testKVP](instance, "SELECT d FROM test_kvp")
+	require.EqualError(t, err, "Error 1054 (42S22): Unknown column 'd' in 'field list'")
+}
+
+func TestSelect(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2')")
+	require.NoError(t, err)
+	kvps, err := mysql.Select[testKVP](instance, "SELECT `key`,`val` FROM test_kvp ORDER BY `key`")
+	require.NoError(t, err)
+	require.Equal(t, []testKVP{{Key: "test", Val: "test"}, {Key: "test2", Val: "test2"}}, kvps)
+	ptrs, err := mysql.Select[*testKVP](instance, "SELECT `key`,`val` FROM test_kvp ORDER BY `key`")
+	require.NoError(t, err)
+	require.Equal(t, []*testKVP{{Key: "test", Val: "test"}, {Key: "test2", Val: "test2"}}, ptrs)
+	keys, err := mysql.Select[sql.NullString](instance, "SELECT `key` FROM test_kvp ORDER BY `key`")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "test", keys[0].String)
+	rows, err := instance.Query("SELECT `key` FROM test_kvp")
+	require.NoError(t, err)
+	_, err = mysql.Select[string](instance, "SELECT `key` FROM test_kvp")
+	require.ErrorIs(t, err, mysql.ErrRowsNotClosed)
+	require.NoError(t, rows.Close())
+}
+
+func TestQueryForT(t *testing.T) {
+	instance := setupInstance(t)
+	_, err := instance.Exec("INSERT INTO test_kvp (`key`,`val`) VALUES('test','test'),('test2','test2')")
+	require.NoError(t, err)
+	var seen []string
+	require.NoError(t, mysql.QueryForT[testKVP](instance, "SELECT `key`,`val` FROM test_kvp ORDER BY `key`").For(func(row testKVP) error {
+		seen = append(seen, row.Key)
+		return nil
+	}))
+	require.Equal(t, []string{"test", "test2"}, seen)
+	require.EqualError(t, mysql.QueryForT[testKVP](instance, "SELECT `key`,`val` FROM test_kvp").For(func(row testKVP) error {
+		return errors.New("hi")
+	}), "hi")
+	require.EqualError(t, mysql.QueryForT[testKVP](instance, "SELECT d FROM test_kvp").For(func(row testKVP) error {
+		return nil
+	}), "Error 1054 (42S22): Unknown column 'd' in 'field list'")
+}
+
+func TestGet_Pointers(t *testing.T) {
+	instance := mysql.NewInstance(context.Background(), internal.OpenFakeDB(t, "typed"))
+	type source struct {
+		Source string `db:"source"`
+	}
+	row, err := mysql.Get[*source](instance, "SELECT source")
+	require.NoError(t, err)
+	require.Equal(t, &source{Source: "typed"}, row)
+	name, err := mysql.Get[*string](instance, "SELECT source")
+	require.NoError(t, err)
+	require.Equal(t, "typed", *name)
+}
@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"github.com/pkg/errors"
+	"github.com/weisbartb/scene"
+)
+
+// beginUnitOfWork opens the scene wide transaction if this instance is running as a unit of work and none is active
+func (d *Instance) beginUnitOfWork() error {
+	if !d.unitOfWork || d.tx != nil {
+		return nil
+	}
+	return d.BeginTx(nil)
+}
+
+// completeUnitOfWork commits the active transaction if the scene completed successfully,
+// otherwise it is rolled back with the scene's error.
+func (d *Instance) completeUnitOfWork(completeErr error) error {
+	if d.tx == nil {
+		return nil
+	}
+	if completeErr != nil && !errors.Is(completeErr, scene.ErrComplete) {
+		return d.rollback(completeErr)
+	}
+	// Rows left open would block the commit
+	if d.currentOpenRows != nil && !d.currentOpenRows.IsClosed() {
+		if err := d.currentOpenRows.Close(); err != nil {
+			return errors.Wrapf(err, "opened on %v", d.lastOpenedLocation)
+		}
+	}
+	return errors.Wrap(d.Commit(), "committing unit of work")
+}
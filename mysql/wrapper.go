@@ -6,15 +6,57 @@ import (
 	"sync/atomic"
 )
 
+// resultErrors wraps the errors of reading results the way the errors of the operation that produced them are,
+// see WithQueryErrors. sql.ErrNoRows is not a failure and is left as is.
+type resultErrors struct {
+	instance *Instance
+	stmt     *Statement
+}
+
+func (r resultErrors) wrap(err error) error {
+	if r.instance == nil || err == nil || err == sql.ErrNoRows {
+		return err
+	}
+	return r.instance.queryError(r.stmt, err)
+}
+
 type Rowsx struct {
 	*sqlx.Rows
 	closed atomic.Bool
+	stats  *queryStats
+	errs   resultErrors
+}
+
+func (r *Rowsx) Next() bool {
+	if r.Rows.Next() {
+		r.stats.scanned(1)
+		return true
+	}
+	return false
 }
 
 func (r *Rowsx) Close() error {
 	r.closed.Store(true)
 	return r.Rows.Close()
 }
+
+// Err gets the error that ended iterating the rows
+func (r *Rowsx) Err() error {
+	return r.errs.wrap(respErrorHandler(r.Rows.Err()))
+}
+func (r *Rowsx) Scan(dest ...any) error {
+	return r.errs.wrap(r.Rows.Scan(dest...))
+}
+func (r *Rowsx) StructScan(dest interface{}) error {
+	return r.errs.wrap(r.Rows.StructScan(dest))
+}
+func (r *Rowsx) MapScan(dest map[string]interface{}) error {
+	return r.errs.wrap(r.Rows.MapScan(dest))
+}
+func (r *Rowsx) SliceScan() ([]interface{}, error) {
+	out, err := r.Rows.SliceScan()
+	return out, r.errs.wrap(err)
+}
 func (r *Rowsx) IsClosed() bool {
 	return r.closed.Load()
 }
@@ -22,50 +64,88 @@ func (r *Rowsx) IsClosed() bool {
 type Rows struct {
 	*sql.Rows
 	closed atomic.Bool
+	stats  *queryStats
+	errs   resultErrors
+}
+
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.stats.scanned(1)
+		return true
+	}
+	return false
 }
 
 func (r *Rows) Close() error {
 	r.closed.Store(true)
 	return r.Rows.Close()
 }
+
+// Err gets the error that ended iterating the rows
+func (r *Rows) Err() error {
+	return r.errs.wrap(respErrorHandler(r.Rows.Err()))
+}
+func (r *Rows) Scan(dest ...any) error {
+	return r.errs.wrap(r.Rows.Scan(dest...))
+}
 func (r *Rows) IsClosed() bool {
 	return r.closed.Load()
 }
 
 type Row struct {
 	*sql.Row
-	err error
+	err   error
+	stats *queryStats
+	errs  resultErrors
 }
 
 func (r *Row) Scan(args ...any) error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.Scan(args...)
+	return r.scanned(r.Row.Scan(args...))
+}
+
+// scanned counts the row once it has been read
+func (r *Row) scanned(err error) error {
+	if err == nil {
+		r.stats.scanned(1)
+	}
+	return r.errs.wrap(err)
 }
 func (r *Row) Err() error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.Err()
+	return r.errs.wrap(r.Row.Err())
 }
 
 type Rowx struct {
 	*sqlx.Row
-	err error
+	err   error
+	stats *queryStats
+	errs  resultErrors
 }
 
 func (r *Rowx) Scan(args ...any) error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.Scan(args...)
+	return r.scanned(r.Row.Scan(args...))
+}
+
+// scanned counts the row once it has been read
+func (r *Rowx) scanned(err error) error {
+	if err == nil {
+		r.stats.scanned(1)
+	}
+	return r.errs.wrap(err)
 }
 func (r *Rowx) Err() error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.Err()
+	return r.errs.wrap(r.Row.Err())
 }
 func (r *Rowx) Columns() ([]string, error) {
 	if r.err != nil {
@@ -83,18 +163,19 @@ func (r *Rowx) SliceScan() ([]interface{}, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
-	return r.Row.SliceScan()
+	out, err := r.Row.SliceScan()
+	return out, r.scanned(err)
 }
 func (r *Rowx) MapScan(dest map[string]interface{}) error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.MapScan(dest)
+	return r.scanned(r.Row.MapScan(dest))
 }
 
 func (r *Rowx) StructScan(dest interface{}) error {
 	if r.err != nil {
 		return r.err
 	}
-	return r.Row.StructScan(dest)
+	return r.scanned(r.Row.StructScan(dest))
 }